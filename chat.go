@@ -1,16 +1,19 @@
 package keybase
 
 import (
-	"bufio"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
-	"errors"
-	"os/exec"
+	"fmt"
 	"strings"
 	"time"
 )
 
+// How long RunContext waits for running handlers to return when it stops, if
+// RunOptions.DrainTimeout isn't set
+const defaultDrainTimeout = 10 * time.Second
+
 // Returns a string representation of a message id suitable for use in a
 // pagination struct
 func getID(id uint) string {
@@ -59,36 +62,22 @@ func createFiltersString(channels []Channel) string {
 	return string(jsonBytes)
 }
 
-// Run `keybase chat api-listen` to get new messages coming into keybase and send them into the channel
-func getNewMessages(k *Keybase, c chan<- ChatAPI, execOptions []string) {
-	execString := []string{"chat", "api-listen"}
-	if len(execOptions) > 0 {
-		execString = append(execString, execOptions...)
-	}
-	for {
-		execCmd := exec.Command(k.Path, execString...)
-		stdOut, _ := execCmd.StdoutPipe()
-		execCmd.Start()
-		scanner := bufio.NewScanner(stdOut)
-		go func(scanner *bufio.Scanner, c chan<- ChatAPI) {
-			for scanner.Scan() {
-				var jsonData ChatAPI
-				json.Unmarshal([]byte(scanner.Text()), &jsonData)
-				if jsonData.ErrorRaw != nil {
-					var errorListen = string(*jsonData.ErrorRaw)
-					jsonData.ErrorListen = &errorListen
-				}
-				c <- jsonData
-			}
-		}(scanner, c)
-		execCmd.Wait()
-	}
+// Run runs `keybase chat api-listen`, and passes incoming messages to the message handler func.
+// Run returns once Close is called.
+func (k *Keybase) Run(handler func(ChatAPI), options ...RunOptions) {
+	k.RunContext(context.Background(), handler, options...)
 }
 
-// Run runs `keybase chat api-listen`, and passes incoming messages to the message handler func
-func (k *Keybase) Run(handler func(ChatAPI), options ...RunOptions) {
+// RunContext is like Run, but also returns when ctx is done. Every message is
+// passed to handler in a new goroutine, subject to RunOptions.MaxHandlers,
+// RunOptions.Ordered and RunOptions.MaxQueued. Before returning, RunContext stops
+// `keybase chat api-listen` and waits up to RunOptions.DrainTimeout for
+// running handlers to return. It returns ctx.Err() if ctx is done, or
+// ErrClosed if Close was called.
+func (k *Keybase) RunContext(ctx context.Context, handler func(ChatAPI), options ...RunOptions) error {
 	var heartbeatFreq int64
 	var channelCapacity = 100
+	var drainTimeout = defaultDrainTimeout
 
 	runOptions := make([]string, 0)
 	if len(options) > 0 {
@@ -98,6 +87,9 @@ func (k *Keybase) Run(handler func(ChatAPI), options ...RunOptions) {
 		if options[0].Heartbeat > 0 {
 			heartbeatFreq = options[0].Heartbeat
 		}
+		if options[0].DrainTimeout > 0 {
+			drainTimeout = options[0].DrainTimeout
+		}
 		if options[0].Local {
 			runOptions = append(runOptions, "--local")
 		}
@@ -107,6 +99,12 @@ func (k *Keybase) Run(handler func(ChatAPI), options ...RunOptions) {
 		if options[0].Dev {
 			runOptions = append(runOptions, "--dev")
 		}
+		if options[0].Convs {
+			runOptions = append(runOptions, "--convs")
+		}
+		if options[0].Wallet || options[0].PaymentHandler != nil {
+			runOptions = append(runOptions, "--wallet")
+		}
 		if len(options[0].FilterChannels) > 0 {
 			runOptions = append(runOptions, "--filter-channels")
 			runOptions = append(runOptions, createFiltersString(options[0].FilterChannels))
@@ -117,49 +115,128 @@ func (k *Keybase) Run(handler func(ChatAPI), options ...RunOptions) {
 			runOptions = append(runOptions, createFilterString(options[0].FilterChannel))
 		}
 	}
+
+	lifetime := k.lifetime()
+	listenCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lifetime.Done():
+			cancel()
+		case <-listenCtx.Done():
+		}
+	}()
+
 	c := make(chan ChatAPI, channelCapacity)
-	defer close(c)
 	if heartbeatFreq > 0 {
-		go heartbeat(c, time.Duration(heartbeatFreq)*time.Minute)
+		go heartbeat(listenCtx, c, time.Duration(heartbeatFreq)*time.Minute)
+	}
+	var opts RunOptions
+	if len(options) > 0 {
+		opts = options[0]
 	}
-	go getNewMessages(k, c, runOptions)
+	listening := make(chan struct{})
+	go func() {
+		newListener(k, c, runOptions, opts).run(listenCtx)
+		close(listening)
+	}()
+
+	d := newDispatcher(listenCtx, k, handler, opts)
+	metrics := k.getMetrics()
+loop:
 	for {
-		go handler(<-c)
+		select {
+		case m := <-c:
+			metrics.SetQueueDepth(len(c))
+			if !d.dispatch(m) {
+				break loop
+			}
+		case <-listenCtx.Done():
+			break loop
+		}
+	}
+
+	err := ctx.Err()
+	if err == nil {
+		err = ErrClosed
+	}
+
+	// Wait for the listener's subprocess to exit, and for handlers to return
+	drained := make(chan struct{})
+	go func() {
+		<-listening
+		<-d.wait()
+		close(drained)
+	}()
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("keybase: listener didn't shut down within %s: %w", drainTimeout, err)
 	}
 }
 
 // heartbeat sends a message through the channel with a message type of `heartbeat`
-func heartbeat(c chan<- ChatAPI, freq time.Duration) {
-	m := ChatAPI{
-		Type: "heartbeat",
-	}
+func heartbeat(ctx context.Context, c chan<- ChatAPI, freq time.Duration) {
+	ticker := time.NewTicker(freq)
+	defer ticker.Stop()
 	count := 0
 	for {
-		time.Sleep(freq)
-		m.Msg.ID = count
-		c <- m
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		m := ChatAPI{
+			Type: "heartbeat",
+			Msg:  &Message{ID: count},
+		}
+		select {
+		case c <- m:
+		case <-ctx.Done():
+			return
+		}
 		count++
 	}
 }
 
 // chatAPIOut sends JSON requests to the chat API and returns its response.
-func chatAPIOut(k *Keybase, c ChatAPI) (ChatAPI, error) {
+// Requests are delayed while the chat API's rate limits would reject them, and
+// retried according to k's RetryPolicy, including when they were rate limited.
+func chatAPIOut(ctx context.Context, k *Keybase, c ChatAPI) (ChatAPI, error) {
+	if err := k.checkSupported("chat", c.Method); err != nil {
+		return ChatAPI{}, err
+	}
+	var r ChatAPI
+	err := k.retry(ctx, "chat", c.Method, func(ctx context.Context) (err error) {
+		r, err = k.withRateLimits(ctx, c.Method, func() (ChatAPI, error) {
+			return chatAPICall(ctx, k, c)
+		})
+		return err
+	})
+	return r, err
+}
+
+// chatAPICall sends a single JSON request to the chat API
+func chatAPICall(ctx context.Context, k *Keybase, c ChatAPI) (ChatAPI, error) {
 	jsonBytes, _ := json.Marshal(c)
 
-	cmdOut, err := k.Exec("chat", "api", "-m", string(jsonBytes))
+	cmdOut, err := k.api(ctx, "chat", c.Method, jsonBytes)
 	if err != nil {
-		return ChatAPI{}, err
+		return ChatAPI{}, withMethod(err, c.Method)
 	}
 
 	var r ChatAPI
 	if err := json.Unmarshal(cmdOut, &r); err != nil {
-		return ChatAPI{}, err
+		return ChatAPI{}, decodeError(c.Method, err)
 	}
 	if r.ErrorRaw != nil {
 		var errorRead Error
 		json.Unmarshal([]byte(*r.ErrorRaw), &errorRead)
 		r.ErrorRead = &errorRead
-		return r, errors.New(r.ErrorRead.Message)
+		return r, newResponseError(c.Method, errorRead)
 	}
 
 	return r, nil
@@ -167,18 +244,23 @@ func chatAPIOut(k *Keybase, c ChatAPI) (ChatAPI, error) {
 
 // Send sends a chat message
 func (c Chat) Send(message ...string) (ChatAPI, error) {
+	return c.SendContext(context.Background(), message...)
+}
+
+// SendContext is like Send, but aborts the request when ctx is done
+func (c Chat) SendContext(ctx context.Context, message ...string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Message: &mesg{},
+	m.Params.Options = ChatOptions{
+		Message: &MessageBody{},
 	}
 
 	m.Method = "send"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Message.Body = strings.Join(message, " ")
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -187,18 +269,23 @@ func (c Chat) Send(message ...string) (ChatAPI, error) {
 
 // SendEphemeral sends an exploding chat message, with specified duration
 func (c Chat) SendEphemeral(duration time.Duration, message ...string) (ChatAPI, error) {
+	return c.SendEphemeralContext(context.Background(), duration, message...)
+}
+
+// SendEphemeralContext is like SendEphemeral, but aborts the request when ctx is done
+func (c Chat) SendEphemeralContext(ctx context.Context, duration time.Duration, message ...string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Message: &mesg{},
+	m.Params.Options = ChatOptions{
+		Message: &MessageBody{},
 	}
 	m.Params.Options.ExplodingLifetime.Duration = duration
 	m.Method = "send"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Message.Body = strings.Join(message, " ")
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -207,11 +294,16 @@ func (c Chat) SendEphemeral(duration time.Duration, message ...string) (ChatAPI,
 
 // Reply sends a reply to a chat message
 func (c Chat) Reply(replyTo int, message ...string) (ChatAPI, error) {
+	return c.ReplyContext(context.Background(), replyTo, message...)
+}
+
+// ReplyContext is like Reply, but aborts the request when ctx is done
+func (c Chat) ReplyContext(ctx context.Context, replyTo int, message ...string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Message: &mesg{},
+	m.Params.Options = ChatOptions{
+		Message: &MessageBody{},
 	}
 
 	m.Method = "send"
@@ -219,7 +311,7 @@ func (c Chat) Reply(replyTo int, message ...string) (ChatAPI, error) {
 	m.Params.Options.ReplyTo = replyTo
 	m.Params.Options.Message.Body = strings.Join(message, " ")
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -228,18 +320,23 @@ func (c Chat) Reply(replyTo int, message ...string) (ChatAPI, error) {
 
 // Edit edits a previously sent chat message
 func (c Chat) Edit(messageID int, message ...string) (ChatAPI, error) {
+	return c.EditContext(context.Background(), messageID, message...)
+}
+
+// EditContext is like Edit, but aborts the request when ctx is done
+func (c Chat) EditContext(ctx context.Context, messageID int, message ...string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Message: &mesg{},
+	m.Params.Options = ChatOptions{
+		Message: &MessageBody{},
 	}
 	m.Method = "edit"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Message.Body = strings.Join(message, " ")
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -248,18 +345,23 @@ func (c Chat) Edit(messageID int, message ...string) (ChatAPI, error) {
 
 // React sends a reaction to a message.
 func (c Chat) React(messageID int, reaction string) (ChatAPI, error) {
+	return c.ReactContext(context.Background(), messageID, reaction)
+}
+
+// ReactContext is like React, but aborts the request when ctx is done
+func (c Chat) ReactContext(ctx context.Context, messageID int, reaction string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Message: &mesg{},
+	m.Params.Options = ChatOptions{
+		Message: &MessageBody{},
 	}
 	m.Method = "reaction"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Message.Body = reaction
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -268,14 +370,19 @@ func (c Chat) React(messageID int, reaction string) (ChatAPI, error) {
 
 // Delete deletes a chat message
 func (c Chat) Delete(messageID int) (ChatAPI, error) {
+	return c.DeleteContext(context.Background(), messageID)
+}
+
+// DeleteContext is like Delete, but aborts the request when ctx is done
+func (c Chat) DeleteContext(ctx context.Context, messageID int) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "delete"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -286,8 +393,13 @@ func (c Chat) Delete(messageID int) (ChatAPI, error) {
 // You can pass a Channel to use as a filter here, but you'll probably want to
 // leave the TopicName empty.
 func (k *Keybase) ChatList(opts ...Channel) (ChatAPI, error) {
+	return k.ChatListContext(context.Background(), opts...)
+}
+
+// ChatListContext is like ChatList, but aborts the request when ctx is done
+func (k *Keybase) ChatListContext(ctx context.Context, opts ...Channel) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 
 	if len(opts) > 0 {
@@ -299,17 +411,22 @@ func (k *Keybase) ChatList(opts ...Channel) (ChatAPI, error) {
 	}
 	m.Method = "list"
 
-	r, err := chatAPIOut(k, m)
+	r, err := chatAPIOut(ctx, k, m)
 	return r, err
 }
 
 // ReadMessage fetches the chat message with the specified message id from a conversation.
 func (c Chat) ReadMessage(messageID int) (*ChatAPI, error) {
+	return c.ReadMessageContext(context.Background(), messageID)
+}
+
+// ReadMessageContext is like ReadMessage, but aborts the request when ctx is done
+func (c Chat) ReadMessageContext(ctx context.Context, messageID int) (*ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Pagination: &pagination{},
+	m.Params.Options = ChatOptions{
+		Pagination: &Pagination{},
 	}
 
 	m.Method = "read"
@@ -318,11 +435,11 @@ func (c Chat) ReadMessage(messageID int) (*ChatAPI, error) {
 
 	m.Params.Options.Pagination.Previous = getID(uint(messageID - 1))
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return &r, err
 	}
-	r.keybase = *c.keybase
+	r.keybase = c.keybase
 	return &r, nil
 }
 
@@ -330,11 +447,16 @@ func (c Chat) ReadMessage(messageID int) (*ChatAPI, error) {
 // be fetched at a time. However, if count is passed, then that is the number of
 // messages that will be fetched.
 func (c Chat) Read(count ...int) (*ChatAPI, error) {
+	return c.ReadContext(context.Background(), count...)
+}
+
+// ReadContext is like Read, but aborts the request when ctx is done
+func (c Chat) ReadContext(ctx context.Context, count ...int) (*ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Pagination: &pagination{},
+	m.Params.Options = ChatOptions{
+		Pagination: &Pagination{},
 	}
 
 	m.Method = "read"
@@ -345,11 +467,11 @@ func (c Chat) Read(count ...int) (*ChatAPI, error) {
 		m.Params.Options.Pagination.Num = count[0]
 	}
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return &r, err
 	}
-	r.keybase = *c.keybase
+	r.keybase = c.keybase
 	return &r, nil
 }
 
@@ -358,11 +480,16 @@ func (c Chat) Read(count ...int) (*ChatAPI, error) {
 // fetched with Read. However, if count is passed, then that is the number of
 // messages that will be fetched.
 func (c *ChatAPI) Next(count ...int) (*ChatAPI, error) {
+	return c.NextContext(context.Background(), count...)
+}
+
+// NextContext is like Next, but aborts the request when ctx is done
+func (c *ChatAPI) NextContext(ctx context.Context, count ...int) (*ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Pagination: &pagination{},
+	m.Params.Options = ChatOptions{
+		Pagination: &Pagination{},
 	}
 
 	m.Method = "read"
@@ -374,7 +501,7 @@ func (c *ChatAPI) Next(count ...int) (*ChatAPI, error) {
 	}
 	m.Params.Options.Pagination.Next = c.Result.Pagination.Next
 
-	result, err := chatAPIOut(&c.keybase, m)
+	result, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return &result, err
 	}
@@ -389,11 +516,16 @@ func (c *ChatAPI) Next(count ...int) (*ChatAPI, error) {
 // originally fetched with Read. However, if count is passed, then that is the
 // number of messages that will be fetched.
 func (c *ChatAPI) Previous(count ...int) (*ChatAPI, error) {
+	return c.PreviousContext(context.Background(), count...)
+}
+
+// PreviousContext is like Previous, but aborts the request when ctx is done
+func (c *ChatAPI) PreviousContext(ctx context.Context, count ...int) (*ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
-	m.Params.Options = options{
-		Pagination: &pagination{},
+	m.Params.Options = ChatOptions{
+		Pagination: &Pagination{},
 	}
 
 	m.Method = "read"
@@ -405,7 +537,7 @@ func (c *ChatAPI) Previous(count ...int) (*ChatAPI, error) {
 	}
 	m.Params.Options.Pagination.Previous = c.Result.Pagination.Previous
 
-	result, err := chatAPIOut(&c.keybase, m)
+	result, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return &result, err
 	}
@@ -418,15 +550,20 @@ func (c *ChatAPI) Previous(count ...int) (*ChatAPI, error) {
 // Upload attaches a file to a conversation
 // The filepath must be an absolute path
 func (c Chat) Upload(title string, filepath string) (ChatAPI, error) {
+	return c.UploadContext(context.Background(), title, filepath)
+}
+
+// UploadContext is like Upload, but aborts the request when ctx is done
+func (c Chat) UploadContext(ctx context.Context, title string, filepath string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "attach"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Filename = filepath
 	m.Params.Options.Title = title
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -435,15 +572,20 @@ func (c Chat) Upload(title string, filepath string) (ChatAPI, error) {
 
 // Download downloads a file from a conversation
 func (c Chat) Download(messageID int, filepath string) (ChatAPI, error) {
+	return c.DownloadContext(context.Background(), messageID, filepath)
+}
+
+// DownloadContext is like Download, but aborts the request when ctx is done
+func (c Chat) DownloadContext(ctx context.Context, messageID int, filepath string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "download"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.Output = filepath
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -453,8 +595,13 @@ func (c Chat) Download(messageID int, filepath string) (ChatAPI, error) {
 // LoadFlip returns the results of a flip
 // If the flip is still in progress, this can be expected to change if called again
 func (c Chat) LoadFlip(messageID int, conversationID string, flipConversationID string, gameID string) (ChatAPI, error) {
+	return c.LoadFlipContext(context.Background(), messageID, conversationID, flipConversationID, gameID)
+}
+
+// LoadFlipContext is like LoadFlip, but aborts the request when ctx is done
+func (c Chat) LoadFlipContext(ctx context.Context, messageID int, conversationID string, flipConversationID string, gameID string) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "loadflip"
 	m.Params.Options.Channel = &c.Channel
@@ -463,7 +610,7 @@ func (c Chat) LoadFlip(messageID int, conversationID string, flipConversationID
 	m.Params.Options.FlipConversationID = flipConversationID
 	m.Params.Options.GameID = gameID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -472,14 +619,19 @@ func (c Chat) LoadFlip(messageID int, conversationID string, flipConversationID
 
 // Pin pins a message to a channel
 func (c Chat) Pin(messageID int) (ChatAPI, error) {
+	return c.PinContext(context.Background(), messageID)
+}
+
+// PinContext is like Pin, but aborts the request when ctx is done
+func (c Chat) PinContext(ctx context.Context, messageID int) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "pin"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -488,13 +640,18 @@ func (c Chat) Pin(messageID int) (ChatAPI, error) {
 
 // Unpin clears any pinned messages from a channel
 func (c Chat) Unpin() (ChatAPI, error) {
+	return c.UnpinContext(context.Background())
+}
+
+// UnpinContext is like Unpin, but aborts the request when ctx is done
+func (c Chat) UnpinContext(ctx context.Context) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "unpin"
 	m.Params.Options.Channel = &c.Channel
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -503,14 +660,19 @@ func (c Chat) Unpin() (ChatAPI, error) {
 
 // Mark marks a conversation as read up to a specified message
 func (c Chat) Mark(messageID int) (ChatAPI, error) {
+	return c.MarkContext(context.Background(), messageID)
+}
+
+// MarkContext is like Mark, but aborts the request when ctx is done
+func (c Chat) MarkContext(ctx context.Context, messageID int) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "mark"
 	m.Params.Options.Channel = &c.Channel
 	m.Params.Options.MessageID = messageID
 
-	r, err := chatAPIOut(c.keybase, m)
+	r, err := chatAPIOut(ctx, c.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -519,10 +681,15 @@ func (c Chat) Mark(messageID int) (ChatAPI, error) {
 
 // ClearCommands clears bot advertisements
 func (k *Keybase) ClearCommands() (ChatAPI, error) {
+	return k.ClearCommandsContext(context.Background())
+}
+
+// ClearCommandsContext is like ClearCommands, but aborts the request when ctx is done
+func (k *Keybase) ClearCommandsContext(ctx context.Context) (ChatAPI, error) {
 	m := ChatAPI{}
 	m.Method = "clearcommands"
 
-	r, err := chatAPIOut(k, m)
+	r, err := chatAPIOut(ctx, k, m)
 	if err != nil {
 		return r, err
 	}
@@ -533,13 +700,18 @@ func (k *Keybase) ClearCommands() (ChatAPI, error) {
 // This method allows you to set up multiple different types of advertisements at once.
 // Use this method if you have commands whose visibility differs from each other.
 func (k *Keybase) AdvertiseCommands(advertisements []BotAdvertisement) (ChatAPI, error) {
+	return k.AdvertiseCommandsContext(context.Background(), advertisements)
+}
+
+// AdvertiseCommandsContext is like AdvertiseCommands, but aborts the request when ctx is done
+func (k *Keybase) AdvertiseCommandsContext(ctx context.Context, advertisements []BotAdvertisement) (ChatAPI, error) {
 	m := ChatAPI{
-		Params: &params{},
+		Params: &ChatParams{},
 	}
 	m.Method = "advertisecommands"
 	m.Params.Options.BotAdvertisements = advertisements
 
-	r, err := chatAPIOut(k, m)
+	r, err := chatAPIOut(ctx, k, m)
 	if err != nil {
 		return r, err
 	}
@@ -550,7 +722,100 @@ func (k *Keybase) AdvertiseCommands(advertisements []BotAdvertisement) (ChatAPI,
 // This method allows you to set up one type of advertisement.
 // Use this method if you have commands whose visibility should all be the same.
 func (k *Keybase) AdvertiseCommand(advertisement BotAdvertisement) (ChatAPI, error) {
-	return k.AdvertiseCommands([]BotAdvertisement{
+	return k.AdvertiseCommandContext(context.Background(), advertisement)
+}
+
+// AdvertiseCommandContext is like AdvertiseCommand, but aborts the request when ctx is done
+func (k *Keybase) AdvertiseCommandContext(ctx context.Context, advertisement BotAdvertisement) (ChatAPI, error) {
+	return k.AdvertiseCommandsContext(ctx, []BotAdvertisement{
 		advertisement,
 	})
 }
+
+// AddEmoji uploads a custom emoji to a conversation. The filepath must be an
+// absolute path. Requires keybase 5.6.0 or newer.
+func (c Chat) AddEmoji(alias string, filepath string) (ChatAPI, error) {
+	return c.AddEmojiContext(context.Background(), alias, filepath)
+}
+
+// AddEmojiContext is like AddEmoji, but aborts the request when ctx is done
+func (c Chat) AddEmojiContext(ctx context.Context, alias string, filepath string) (ChatAPI, error) {
+	m := ChatAPI{
+		Params: &ChatParams{},
+	}
+	m.Method = "addemoji"
+	m.Params.Options.Channel = &c.Channel
+	m.Params.Options.Alias = alias
+	m.Params.Options.Filename = filepath
+
+	r, err := chatAPIOut(ctx, c.keybase, m)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// AddBotMember adds a bot to a conversation with the given role, which is
+// usually "restrictedbot". Requires keybase 5.2.0 or newer.
+func (c Chat) AddBotMember(username string, role string) (ChatAPI, error) {
+	return c.AddBotMemberContext(context.Background(), username, role)
+}
+
+// AddBotMemberContext is like AddBotMember, but aborts the request when ctx is done
+func (c Chat) AddBotMemberContext(ctx context.Context, username string, role string) (ChatAPI, error) {
+	m := ChatAPI{
+		Params: &ChatParams{},
+	}
+	m.Method = "addbotmember"
+	m.Params.Options.Channel = &c.Channel
+	m.Params.Options.Username = username
+	m.Params.Options.Role = role
+
+	r, err := chatAPIOut(ctx, c.keybase, m)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// RemoveBotMember removes a bot from a conversation. Requires keybase 5.2.0 or newer.
+func (c Chat) RemoveBotMember(username string) (ChatAPI, error) {
+	return c.RemoveBotMemberContext(context.Background(), username)
+}
+
+// RemoveBotMemberContext is like RemoveBotMember, but aborts the request when ctx is done
+func (c Chat) RemoveBotMemberContext(ctx context.Context, username string) (ChatAPI, error) {
+	m := ChatAPI{
+		Params: &ChatParams{},
+	}
+	m.Method = "removebotmember"
+	m.Params.Options.Channel = &c.Channel
+	m.Params.Options.Username = username
+
+	r, err := chatAPIOut(ctx, c.keybase, m)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// SearchInbox searches all conversations for messages matching the query.
+// Requires keybase 4.4.0 or newer.
+func (k *Keybase) SearchInbox(query string) (ChatAPI, error) {
+	return k.SearchInboxContext(context.Background(), query)
+}
+
+// SearchInboxContext is like SearchInbox, but aborts the request when ctx is done
+func (k *Keybase) SearchInboxContext(ctx context.Context, query string) (ChatAPI, error) {
+	m := ChatAPI{
+		Params: &ChatParams{},
+	}
+	m.Method = "searchinbox"
+	m.Params.Options.Query = query
+
+	r, err := chatAPIOut(ctx, k, m)
+	if err != nil {
+		return r, err
+	}
+	return r, nil
+}
@@ -0,0 +1,163 @@
+package keybase
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// dispatcher passes the messages received by RunContext to its handler, each
+// in its own goroutine
+type dispatcher struct {
+	ctx       context.Context
+	handler   func(ChatAPI)
+	payments  func(PaymentNotification) // Receives wallet events instead of handler, if set
+	metrics   Metrics
+	slots     chan struct{} // Holds a value for every message being handled, if the number of handlers is limited
+	ordered   bool          // Whether messages from the same conversation are handled one at a time, in order
+	maxQueued int           // Maximum length of each of queues
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	queued *sync.Cond           // Signalled when a message is taken from queues, or ctx is done. Uses mu
+	queues map[string][]ChatAPI // Messages waiting for the previous message from their conversation to be handled
+}
+
+// Default for RunOptions.MaxQueued
+const defaultMaxQueued = 100
+
+// newDispatcher returns a dispatcher for the given options. Messages are
+// dropped once ctx is done.
+func newDispatcher(ctx context.Context, k *Keybase, handler func(ChatAPI), opts RunOptions) *dispatcher {
+	d := &dispatcher{
+		ctx:       ctx,
+		handler:   handler,
+		payments:  opts.PaymentHandler,
+		metrics:   k.getMetrics(),
+		ordered:   opts.Ordered,
+		maxQueued: defaultMaxQueued,
+		queues:    make(map[string][]ChatAPI),
+	}
+	d.queued = sync.NewCond(&d.mu)
+	if opts.MaxHandlers > 0 {
+		d.slots = make(chan struct{}, opts.MaxHandlers)
+	}
+	if opts.MaxQueued > 0 {
+		d.maxQueued = opts.MaxQueued
+	}
+	if d.ordered {
+		go func() {
+			<-ctx.Done()
+			d.mu.Lock()
+			d.queued.Broadcast()
+			d.mu.Unlock()
+		}()
+	}
+	return d
+}
+
+// dispatch hands m to a handler. If the number of handlers is limited, or m's
+// conversation already has as many messages queued as allowed, it blocks
+// until m can be handled or queued, and returns false if ctx is done first.
+func (d *dispatcher) dispatch(m ChatAPI) bool {
+	conv := ""
+	if d.ordered && m.Msg != nil {
+		conv = m.Msg.ConversationID
+	}
+	if conv == "" {
+		if !d.acquire() {
+			return false
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.handle(m)
+		}()
+		return true
+	}
+
+	// Messages waiting for their conversation don't take a slot, so that a
+	// busy conversation can't hold up the others
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for len(d.queues[conv]) >= d.maxQueued && d.ctx.Err() == nil {
+		d.queued.Wait()
+	}
+	if d.ctx.Err() != nil {
+		return false
+	}
+	if queue, busy := d.queues[conv]; busy {
+		d.queues[conv] = append(queue, m)
+		return true
+	}
+	d.queues[conv] = nil
+	d.wg.Add(1)
+	go d.drain(conv, m)
+	return true
+}
+
+// drain handles m, followed by the messages queued for the same conversation
+// while it was being handled. Each message takes a slot when it starts.
+func (d *dispatcher) drain(conv string, m ChatAPI) {
+	defer d.wg.Done()
+	for {
+		if d.ctx.Err() == nil && d.acquire() {
+			d.handle(m)
+		}
+
+		d.mu.Lock()
+		queue := d.queues[conv]
+		if len(queue) == 0 {
+			delete(d.queues, conv)
+			d.mu.Unlock()
+			return
+		}
+		m = queue[0]
+		d.queues[conv] = queue[1:]
+		d.queued.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+// acquire takes a slot for a handler, if the number of handlers is limited.
+// It returns false if ctx is done first.
+func (d *dispatcher) acquire() bool {
+	if d.slots == nil {
+		return true
+	}
+	select {
+	case d.slots <- struct{}{}:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// handle calls the handler, and frees m's slot afterwards
+func (d *dispatcher) handle(m ChatAPI) {
+	defer d.release()
+	start := time.Now()
+	if p, ok := m.PaymentNotification(); ok && d.payments != nil {
+		d.payments(p)
+	} else if d.handler != nil {
+		d.handler(m)
+	}
+	d.metrics.ObserveHandler(time.Since(start))
+}
+
+// release frees a slot taken by acquire
+func (d *dispatcher) release() {
+	if d.slots != nil {
+		<-d.slots
+	}
+}
+
+// wait returns a channel that's closed once every handler has returned
+func (d *dispatcher) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	return done
+}
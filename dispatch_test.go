@@ -0,0 +1,147 @@
+package keybase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchMaxHandlers(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	handler := func(ChatAPI) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+
+	d := newDispatcher(context.Background(), &Keybase{}, handler, RunOptions{MaxHandlers: 2})
+	dispatched := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			d.dispatch(ChatAPI{})
+		}
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch didn't wait for a free handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-dispatched
+	<-d.wait()
+	if peak != 2 {
+		t.Errorf("%d handlers ran at once, want 2", peak)
+	}
+
+	// dispatch gives up once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	defer close(block)
+	d = newDispatcher(ctx, &Keybase{}, func(ChatAPI) { <-block }, RunOptions{MaxHandlers: 1})
+	d.dispatch(ChatAPI{})
+	cancel()
+	if d.dispatch(ChatAPI{}) {
+		t.Error("dispatch succeeded after the context was cancelled")
+	}
+}
+
+func TestDispatchOrdered(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]int{}
+	blockA := make(chan struct{})
+	startedB := make(chan struct{}, 3)
+	handler := func(m ChatAPI) {
+		if m.Msg.ConversationID == "a" && m.Msg.ID == 0 {
+			<-blockA
+		}
+		if m.Msg.ConversationID == "b" {
+			startedB <- struct{}{}
+		}
+		mu.Lock()
+		got[m.Msg.ConversationID] = append(got[m.Msg.ConversationID], m.Msg.ID)
+		mu.Unlock()
+	}
+
+	d := newDispatcher(context.Background(), &Keybase{}, handler, RunOptions{Ordered: true})
+	for i := 0; i < 3; i++ {
+		d.dispatch(ChatAPI{Msg: &Message{ID: i, ConversationID: "a"}})
+		d.dispatch(ChatAPI{Msg: &Message{ID: i, ConversationID: "b"}})
+	}
+
+	// Conversation b isn't held up by the slow handler in conversation a
+	for i := 0; i < 3; i++ {
+		select {
+		case <-startedB:
+		case <-time.After(5 * time.Second):
+			t.Fatal("conversation b was blocked by conversation a")
+		}
+	}
+	close(blockA)
+	<-d.wait()
+
+	for _, conv := range []string{"a", "b"} {
+		if ids := got[conv]; len(ids) != 3 || ids[0] != 0 || ids[1] != 1 || ids[2] != 2 {
+			t.Errorf("conversation %s handled in order %v", conv, ids)
+		}
+	}
+}
+
+func TestDispatchOrderedMaxHandlers(t *testing.T) {
+	blockA := make(chan struct{})
+	startedB := make(chan int, 3)
+	handler := func(m ChatAPI) {
+		switch m.Msg.ConversationID {
+		case "a":
+			<-blockA
+		case "b":
+			startedB <- m.Msg.ID
+		}
+	}
+
+	d := newDispatcher(context.Background(), &Keybase{}, handler, RunOptions{MaxHandlers: 2, Ordered: true, MaxQueued: 5})
+	dispatched := make(chan struct{})
+	go func() {
+		// A burst from a slow conversation only holds one slot...
+		for i := 0; i < 6; i++ {
+			d.dispatch(ChatAPI{Msg: &Message{ID: i, ConversationID: "a"}})
+		}
+		// ... so the other conversation still runs
+		for i := 0; i < 3; i++ {
+			d.dispatch(ChatAPI{Msg: &Message{ID: i, ConversationID: "b"}})
+		}
+		// ... until conversation a has MaxQueued messages waiting
+		d.dispatch(ChatAPI{Msg: &Message{ID: 6, ConversationID: "a"}})
+		close(dispatched)
+	}()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-startedB:
+			if id != i {
+				t.Errorf("conversation b handled %d, want %d", id, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("conversation b was blocked by conversation a")
+		}
+	}
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch didn't wait for room in conversation a's queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(blockA)
+	<-dispatched
+	<-d.wait()
+}
@@ -62,5 +62,94 @@ in @mkbot#test1:
     		chat.React(msgID, deviceName)
     	}
     }
+
+Multiple Accounts
+
+Each Keybase value can use its own keybase home directory, which gives it its own service, config, and
+logged-in user. The home directory is passed to every command that Keybase runs, including `chat api-listen`,
+so several bots can run side by side in one process:
+
+    alice, err := keybase.New(keybase.WithHome("/var/lib/bots/alice"))
+    if err != nil {
+    	log.Fatal(err)
+    }
+    bob, err := keybase.New(keybase.WithHome("/var/lib/bots/bob"))
+    if err != nil {
+    	log.Fatal(err)
+    }
+    go alice.Run(aliceHandler)
+    bob.Run(bobHandler)
+
+Headless Bots
+
+Bots running in containers usually have no keybase service running, and no logged-in user. WithService starts a
+private service for the Keybase, and Oneshot logs in with a paper key without provisioning a new device. Close
+stops the service, along with any listeners and API sessions:
+
+    k, err := keybase.New(keybase.WithHome("/tmp/bot"), keybase.WithService())
+    if err != nil {
+    	log.Fatal(err)
+    }
+    defer k.Close()
+
+    if err := k.Oneshot(os.Getenv("KEYBASE_USERNAME"), os.Getenv("KEYBASE_PAPERKEY")); err != nil {
+    	log.Fatal(err)
+    }
+
+RunContext returns when its context is done, after waiting for running handlers, so a bot can shut down cleanly
+when it's asked to stop:
+
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+    defer stop()
+    if err := k.RunContext(ctx, handler); !errors.Is(err, context.Canceled) {
+    	log.Print(err)
+    }
+
+Events
+
+RunEvents passes what `chat api-listen` receives to its handler as typed Events, instead of ChatAPI values. They can
+be told apart with a type switch, or with an EventMux that calls a callback for each kind of event:
+
+    mux := &keybase.EventMux{}
+    mux.OnChatMessage(func(e keybase.ChatMessageEvent) {
+    	fmt.Println(e.Message.Sender.Username, e.Message.Content.Text.Body)
+    })
+    mux.OnWallet(func(e keybase.WalletEvent) {
+    	if e.Payment.Incoming() && e.Payment.Completed() {
+    		fmt.Println("received", e.Payment.Amount)
+    	}
+    })
+    err := k.RunEvents(ctx, mux.Handle, keybase.RunOptions{Wallet: true})
+
+Recording and Replaying
+
+A Recorder writes every command a Keybase runs, along with its output, to a transcript. A Replayer serves the
+transcript back without running keybase at all, and fails any request that wasn't recorded, which makes it easy
+to capture real behavior once and run regression tests against it offline. Secrets are redacted from transcripts
+according to the Keybase's Redaction policy, so they can be committed:
+
+    f, _ := os.Create("testdata/bot.transcript")
+    rec := keybase.NewRecorder(f)
+    k, err := keybase.New(keybase.WithRecorder(rec))
+
+    // Later, in tests:
+    f, _ := os.Open("testdata/bot.transcript")
+    r, err := keybase.NewReplayer(f)
+    k, err := keybase.New(keybase.WithExecutor(r))
+
+Mocking
+
+Keybase implements the Client, Runner and EventRunner interfaces, and Chat, Team, KV and Wallet implement the
+ChatClient, TeamClient, KVClient and WalletClient interfaces. NewClients returns a Clients, which hands out the
+latter as interfaces. Code that depends on the interfaces instead of the concrete types can be tested with fakes:
+
+    type bot struct {
+        clients keybase.Clients
+        runner  keybase.Runner
+    }
+
+    b := bot{clients: keybase.NewClients(k), runner: k}
+    // In tests:
+    b := bot{clients: fakeClients{}, runner: fakeRunner{}}
 */
 package keybase
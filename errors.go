@@ -0,0 +1,207 @@
+package keybase
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Classes of errors returned by keybase. Use errors.Is to check whether an
+// error returned by this package belongs to one of them.
+var (
+	ErrNotLoggedIn      = errors.New("keybase: not logged in")
+	ErrRateLimited      = errors.New("keybase: rate limited")
+	ErrNotFound         = errors.New("keybase: not found")
+	ErrRevisionConflict = errors.New("keybase: revision conflict")
+	ErrPermissionDenied = errors.New("keybase: permission denied")
+)
+
+// ErrUnsupported is returned when the installed keybase client is too old to
+// support the requested API method
+var ErrUnsupported = errors.New("keybase: unsupported by this version of keybase")
+
+// ErrServiceNotRunning is returned by New when the keybase service isn't
+// running. Requests that fail because the service can't be reached, e.g.
+// while it's restarting, also belong to this class.
+var ErrServiceNotRunning = errors.New("keybase: service not running")
+
+// ErrClosed is returned by RunContext when it stops because Close was called
+var ErrClosed = errors.New("keybase: closed")
+
+// Status codes returned by the keybase service
+const (
+	scLoginRequired            = 201
+	scBadSession               = 202
+	scNotFound                 = 205
+	scNoSession                = 283
+	scRateLimit                = 602
+	scChatRateLimit            = 2501
+	scChatNotInConv            = 2504
+	scChatNotInTeam            = 2517
+	scTeamNotFound             = 2614
+	scTeamReadError            = 2623
+	scTeamWritePermDenied      = 2625
+	scTeamStorageWrongRevision = 2760
+	scTeamStorageNotFound      = 2762
+)
+
+// errorCodes maps status codes to the class of error they belong to
+var errorCodes = map[int]error{
+	scLoginRequired:            ErrNotLoggedIn,
+	scBadSession:               ErrNotLoggedIn,
+	scNoSession:                ErrNotLoggedIn,
+	scRateLimit:                ErrRateLimited,
+	scChatRateLimit:            ErrRateLimited,
+	scNotFound:                 ErrNotFound,
+	scTeamNotFound:             ErrNotFound,
+	scTeamStorageNotFound:      ErrNotFound,
+	scTeamStorageWrongRevision: ErrRevisionConflict,
+	scChatNotInConv:            ErrPermissionDenied,
+	scChatNotInTeam:            ErrPermissionDenied,
+	scTeamReadError:            ErrPermissionDenied,
+	scTeamWritePermDenied:      ErrPermissionDenied,
+}
+
+// errorMessages maps fragments of error messages to the class of error they
+// belong to. They're only consulted when the status code is unknown, which is
+// the case for failures printed to stderr.
+var errorMessages = []struct {
+	fragment string
+	err      error
+}{
+	{"connection refused", ErrServiceNotRunning},
+	{"dial unix", ErrServiceNotRunning},
+	{"service not running", ErrServiceNotRunning},
+	{"service isn't running", ErrServiceNotRunning},
+	{"not logged in", ErrNotLoggedIn},
+	{"login required", ErrNotLoggedIn},
+	{"no session", ErrNotLoggedIn},
+	{"rate limit", ErrRateLimited},
+	{"ratelimit", ErrRateLimited},
+	{"wrong revision", ErrRevisionConflict},
+	{"revision mismatch", ErrRevisionConflict},
+	{"not found", ErrNotFound},
+	{"does not exist", ErrNotFound},
+	{"permission denied", ErrPermissionDenied},
+	{"not a member", ErrPermissionDenied},
+}
+
+// APIError is returned when a keybase command fails, or when one of the JSON
+// APIs returns an error
+type APIError struct {
+	Method   string // JSON API method, or the keybase command that was run
+	Code     int    // Status code returned by the API, if any
+	Message  string // Error message returned by the API, if any
+	Stderr   string // Anything the command wrote to stderr
+	ExitCode int    // Exit status of the command, or -1 if it didn't exit
+	Err      error  // Underlying error, such as an *exec.ExitError
+}
+
+// Error returns the error message. For errors returned by the JSON APIs, this
+// is the message the API returned.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	msg := "keybase " + e.Method
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e belongs to the class of errors represented by target,
+// such as ErrNotLoggedIn
+func (e *APIError) Is(target error) bool {
+	return target != nil && e.class() == target
+}
+
+// class returns the sentinel error matching e, or nil if there isn't one
+func (e *APIError) class() error {
+	if err, ok := errorCodes[e.Code]; ok {
+		return err
+	}
+	text := strings.ToLower(e.Message + "\n" + e.Stderr)
+	for _, m := range errorMessages {
+		if strings.Contains(text, m.fragment) {
+			return m.err
+		}
+	}
+	return nil
+}
+
+// newExecError wraps an error returned by an Executor in an *APIError
+func newExecError(command []string, err error) error {
+	e := &APIError{
+		Method:   commandName(command),
+		ExitCode: -1,
+		Err:      err,
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.Stderr = string(exitErr.Stderr)
+		e.ExitCode = exitErr.ExitCode()
+	}
+	var recorded *recordedError
+	if errors.As(err, &recorded) {
+		e.Stderr = recorded.stderr
+		e.ExitCode = recorded.exitCode
+	}
+	return e
+}
+
+// newResponseError returns an *APIError for an error returned by one of the
+// JSON APIs
+func newResponseError(method string, apiErr Error) error {
+	return &APIError{
+		Method:   method,
+		Code:     apiErr.Code,
+		Message:  apiErr.Message,
+		ExitCode: -1,
+	}
+}
+
+// withMethod sets the Method of err, if it's an *APIError. This is used to
+// replace the command name with the JSON API method in errors returned by
+// the API helpers.
+func withMethod(err error, method string) error {
+	var e *APIError
+	if method != "" && errors.As(err, &e) {
+		e.Method = method
+	}
+	return err
+}
+
+// commandName returns the name of a keybase command, without its flags or
+// arguments, e.g. "chat api"
+func commandName(command []string) string {
+	var name []string
+	for _, c := range command {
+		if strings.HasPrefix(c, "-") {
+			break
+		}
+		name = append(name, c)
+		if len(name) == 2 {
+			break
+		}
+	}
+	return strings.Join(name, " ")
+}
+
+// decodeError returns an error describing a response that couldn't be decoded
+func decodeError(method string, err error) error {
+	return &APIError{
+		Method:   method,
+		ExitCode: -1,
+		Err:      fmt.Errorf("decoding response: %w", err),
+	}
+}
@@ -0,0 +1,65 @@
+package keybase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		err    *APIError
+		target error
+	}{
+		{&APIError{Code: scLoginRequired, Message: "login required"}, ErrNotLoggedIn},
+		{&APIError{Stderr: "ERROR You are not logged into Keybase."}, ErrNotLoggedIn},
+		{&APIError{Code: scChatRateLimit}, ErrRateLimited},
+		{&APIError{Code: scTeamStorageWrongRevision, Message: "wrong revision"}, ErrRevisionConflict},
+		{&APIError{Code: scTeamStorageNotFound}, ErrNotFound},
+		{&APIError{Code: scTeamWritePermDenied}, ErrPermissionDenied},
+		{&APIError{Message: "something else went wrong"}, nil},
+	}
+
+	sentinels := []error{ErrNotLoggedIn, ErrRateLimited, ErrNotFound, ErrRevisionConflict, ErrPermissionDenied}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("wrapped: %w", tt.err)
+		for _, s := range sentinels {
+			if got, want := errors.Is(wrapped, s), s == tt.target; got != want {
+				t.Errorf("errors.Is(%+v, %v) = %v, want %v", tt.err, s, got, want)
+			}
+		}
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	err := newResponseError("send", Error{Code: 2505, Message: "bad message"})
+	if err.Error() != "bad message" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "bad message")
+	}
+
+	err = withMethod(newExecError([]string{"chat", "api", "-m", "{}"}, errors.New("exit status 1")), "send")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.Method != "send" {
+		t.Errorf("Method = %q, want %q", apiErr.Method, "send")
+	}
+	if apiErr.ExitCode != -1 {
+		t.Errorf("ExitCode = %d, want -1", apiErr.ExitCode)
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	tests := map[string][]string{
+		"chat api":   {"chat", "api", "-m", "{}"},
+		"status":     {"status", "-j"},
+		"apicall":    {"apicall", "--arg", "username=dxb", "user/card"},
+		"wallet api": {"wallet", "api"},
+	}
+	for want, command := range tests {
+		if got := commandName(command); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+)
+
+// Event is something received by RunEvents: a ChatMessageEvent, WalletEvent,
+// ConversationEvent, HeartbeatEvent, ListenErrorEvent or ListenerEvent. Use a
+// type switch, or an EventMux, to tell them apart.
+type Event interface {
+	// Raw returns the event as it was received from `chat api-listen`
+	Raw() ChatAPI
+}
+
+// ChatMessageEvent is a chat message
+type ChatMessageEvent struct {
+	Message Message
+	Source  string // "local" for messages sent by the current user's devices, "remote" otherwise
+	raw     ChatAPI
+}
+
+// Raw implements Event
+func (e ChatMessageEvent) Raw() ChatAPI { return e.raw }
+
+// WalletEvent is a Stellar payment received or sent by the current user.
+// Wallet events are only received if RunOptions.Wallet is set, and aren't
+// passed to RunEvents's handler if RunOptions.PaymentHandler is set.
+type WalletEvent struct {
+	Payment PaymentNotification
+	raw     ChatAPI
+}
+
+// Raw implements Event
+func (e WalletEvent) Raw() ChatAPI { return e.raw }
+
+// ConversationEvent is a conversation the current user was added to.
+// Conversation events are only received if RunOptions.Convs is set.
+type ConversationEvent struct {
+	Conversation Conversation
+	raw          ChatAPI
+}
+
+// Raw implements Event
+func (e ConversationEvent) Raw() ChatAPI { return e.raw }
+
+// HeartbeatEvent is sent every RunOptions.Heartbeat minutes, if set
+type HeartbeatEvent struct {
+	Count int // Number of heartbeats sent before this one
+	raw   ChatAPI
+}
+
+// Raw implements Event
+func (e HeartbeatEvent) Raw() ChatAPI { return e.raw }
+
+// ListenErrorEvent is an error reported by `chat api-listen`
+type ListenErrorEvent struct {
+	Err error
+	raw ChatAPI
+}
+
+// Raw implements Event
+func (e ListenErrorEvent) Raw() ChatAPI { return e.raw }
+
+// Raw implements Event. ListenerEvents are only received if
+// RunOptions.ListenerEvents is set.
+func (e ListenerEvent) Raw() ChatAPI { return ChatAPI{Type: e.Type, Listener: &e} }
+
+// Event returns c as an Event, or nil if c isn't something that can be
+// received from `chat api-listen`
+func (c ChatAPI) Event() Event {
+	switch {
+	case c.ErrorListen != nil:
+		return ListenErrorEvent{Err: errors.New(*c.ErrorListen), raw: c}
+	case c.Listener != nil:
+		return *c.Listener
+	case c.Type == "heartbeat":
+		e := HeartbeatEvent{raw: c}
+		if c.Msg != nil {
+			e.Count = c.Msg.ID
+		}
+		return e
+	case c.Notification != nil:
+		p, _ := c.PaymentNotification()
+		return WalletEvent{Payment: p, raw: c}
+	case c.Conv != nil:
+		return ConversationEvent{Conversation: *c.Conv, raw: c}
+	case c.Msg != nil:
+		return ChatMessageEvent{Message: *c.Msg, Source: c.Source, raw: c}
+	}
+	return nil
+}
+
+// RunEvents is like RunContext, but passes what it receives to handler as
+// Events
+func (k *Keybase) RunEvents(ctx context.Context, handler func(Event), options ...RunOptions) error {
+	return k.RunContext(ctx, func(m ChatAPI) {
+		if e := m.Event(); e != nil {
+			handler(e)
+		}
+	}, options...)
+}
+
+// EventMux passes Events to the callbacks registered for their kind. Events
+// without a callback are dropped. Callbacks must be registered before the
+// EventMux receives its first event:
+//
+//	mux := &keybase.EventMux{}
+//	mux.OnChatMessage(func(e keybase.ChatMessageEvent) { ... })
+//	mux.OnWallet(func(e keybase.WalletEvent) { ... })
+//	err := k.RunEvents(ctx, mux.Handle)
+type EventMux struct {
+	chatMessage  func(ChatMessageEvent)
+	wallet       func(WalletEvent)
+	conversation func(ConversationEvent)
+	heartbeat    func(HeartbeatEvent)
+	listenError  func(ListenErrorEvent)
+	listener     func(ListenerEvent)
+}
+
+// OnChatMessage registers the callback for ChatMessageEvents
+func (m *EventMux) OnChatMessage(f func(ChatMessageEvent)) { m.chatMessage = f }
+
+// OnWallet registers the callback for WalletEvents
+func (m *EventMux) OnWallet(f func(WalletEvent)) { m.wallet = f }
+
+// OnConversation registers the callback for ConversationEvents
+func (m *EventMux) OnConversation(f func(ConversationEvent)) { m.conversation = f }
+
+// OnHeartbeat registers the callback for HeartbeatEvents
+func (m *EventMux) OnHeartbeat(f func(HeartbeatEvent)) { m.heartbeat = f }
+
+// OnListenError registers the callback for ListenErrorEvents
+func (m *EventMux) OnListenError(f func(ListenErrorEvent)) { m.listenError = f }
+
+// OnListener registers the callback for ListenerEvents
+func (m *EventMux) OnListener(f func(ListenerEvent)) { m.listener = f }
+
+// Handle passes e to the callback registered for its kind, if any
+func (m *EventMux) Handle(e Event) {
+	switch e := e.(type) {
+	case ChatMessageEvent:
+		if m.chatMessage != nil {
+			m.chatMessage(e)
+		}
+	case WalletEvent:
+		if m.wallet != nil {
+			m.wallet(e)
+		}
+	case ConversationEvent:
+		if m.conversation != nil {
+			m.conversation(e)
+		}
+	case HeartbeatEvent:
+		if m.heartbeat != nil {
+			m.heartbeat(e)
+		}
+	case ListenErrorEvent:
+		if m.listenError != nil {
+			m.listenError(e)
+		}
+	case ListenerEvent:
+		if m.listener != nil {
+			m.listener(e)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package keybase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestRunEvents(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	events := make(chan keybase.Event, 10)
+	mux := &keybase.EventMux{}
+	mux.OnChatMessage(func(e keybase.ChatMessageEvent) { events <- e })
+	mux.OnConversation(func(e keybase.ConversationEvent) { events <- e })
+	mux.OnWallet(func(e keybase.WalletEvent) { events <- e })
+	mux.OnListenError(func(e keybase.ListenErrorEvent) { events <- e })
+	mux.OnListener(func(e keybase.ListenerEvent) { events <- e })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go k.RunEvents(ctx, mux.Handle, keybase.RunOptions{
+		Convs:          true,
+		Wallet:         true,
+		ListenerEvents: true,
+		MaxHandlers:    1, // Keeps the events in order
+	})
+	next := func() keybase.Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return nil
+	}
+
+	if e, ok := next().(keybase.ListenerEvent); !ok || e.Type != keybase.ListenerStarted {
+		t.Fatalf("got %#v, want ListenerStarted", e)
+	}
+
+	srv.InjectMessage(keybase.Channel{Name: "alice,bot"}, "alice", "hello")
+	conv, ok := next().(keybase.ConversationEvent)
+	if !ok || conv.Conversation.Channel.Name != "alice,bot" {
+		t.Fatalf("got %#v, want ConversationEvent", conv)
+	}
+	msg, ok := next().(keybase.ChatMessageEvent)
+	if !ok || msg.Message.Content.Text.Body != "hello" || msg.Message.ConversationID != conv.Conversation.ID {
+		t.Fatalf("got %#v, want ChatMessageEvent", msg)
+	}
+	if raw := msg.Raw(); raw.Msg == nil || raw.Msg.ID != msg.Message.ID {
+		t.Errorf("got raw event %+v", raw)
+	}
+
+	srv.InjectPayment("alice", 1, "")
+	if e, ok := next().(keybase.WalletEvent); !ok || !e.Payment.Incoming() {
+		t.Fatalf("got %#v, want WalletEvent", e)
+	}
+
+	srv.InjectListenError("oops")
+	if e, ok := next().(keybase.ListenErrorEvent); !ok || e.Err == nil {
+		t.Fatalf("got %#v, want ListenErrorEvent", e)
+	}
+
+	// Heartbeats are decoded too, and unknown events are dropped
+	if e, ok := (keybase.ChatAPI{Type: "heartbeat", Msg: &keybase.Message{ID: 3}}).Event().(keybase.HeartbeatEvent); !ok || e.Count != 3 {
+		t.Errorf("got %#v, want HeartbeatEvent", e)
+	}
+	if e := (keybase.ChatAPI{Type: "unknown"}).Event(); e != nil {
+		t.Errorf("got %#v for an unknown event", e)
+	}
+}
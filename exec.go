@@ -0,0 +1,219 @@
+package keybase
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// How much of a long-running command's stderr is kept, in bytes
+const maxStderr = 16 << 10
+
+// Executor runs keybase commands on behalf of a Keybase instance. The default
+// Executor runs the local keybase binary, but it can be replaced to run
+// commands remotely, wrap them with instrumentation, or fake them in tests.
+//
+// Some commands need environment variables that are set on their context with
+// WithCommandEnv, e.g. the credentials for `keybase oneshot`. Executors should
+// read them with CommandEnvFrom and pass them on.
+type Executor interface {
+	// Output runs a keybase command to completion and returns its stdout.
+	Output(ctx context.Context, args ...string) ([]byte, error)
+
+	// Start starts a long-running keybase command, such as `chat api-listen`.
+	// The process is killed when ctx is done.
+	Start(ctx context.Context, args ...string) (Process, error)
+}
+
+// Process is a long-running keybase command started by an Executor.
+//
+// A Process may also have a `Stdin() io.WriteCloser` method, which returns
+// the standard input of the command. API sessions need one: if the Processes
+// of an Executor don't have it, every API request starts its own command
+// instead.
+//
+// A Process may also have a `Stderr() string` method, which returns the end of
+// what the command wrote to its standard error. If it does, it's used to
+// report why `chat api-listen` exited. The Processes started by
+// CommandExecutor have both.
+type Process interface {
+	// Stdout returns the standard output of the process
+	Stdout() io.Reader
+
+	// Wait waits for the process to exit
+	Wait() error
+}
+
+// CommandExecutor is an Executor that runs the keybase binary found at Path
+type CommandExecutor struct {
+	Path       string   // Path to the keybase executable
+	Home       string   // Passed to keybase as --home, if set
+	SocketFile string   // Passed to keybase as --socket-file, if set
+	Env        []string // Extra environment variables, in the form "key=value"
+}
+
+// globalFlags returns the flags that have to be passed to every keybase
+// command so that it talks to the right service
+func (e *CommandExecutor) globalFlags() []string {
+	var flags []string
+	if e.Home != "" {
+		flags = append(flags, "--home", e.Home)
+	}
+	if e.SocketFile != "" {
+		flags = append(flags, "--socket-file", e.SocketFile)
+	}
+	return flags
+}
+
+// command returns an *exec.Cmd that runs the given keybase command, with the
+// environment variables set on ctx by WithCommandEnv
+func (e *CommandExecutor) command(ctx context.Context, args []string) *exec.Cmd {
+	args = append(e.globalFlags(), args...)
+	cmd := exec.CommandContext(ctx, e.Path, args...)
+	if env := CommandEnvFrom(ctx); len(e.Env) > 0 || len(env) > 0 {
+		cmd.Env = append(append(os.Environ(), e.Env...), env...)
+	}
+	return cmd
+}
+
+// Output runs the given keybase command and returns its stdout
+func (e *CommandExecutor) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return e.command(ctx, args).Output()
+}
+
+// Start starts the given keybase command and returns a handle to its stdin and stdout
+func (e *CommandExecutor) Start(ctx context.Context, args ...string) (Process, error) {
+	cmd := e.command(ctx, args)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdProcess{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+// commandEnvKey is the context key for WithCommandEnv
+type commandEnvKey struct{}
+
+// WithCommandEnv returns a context that passes the given environment
+// variables, in the form "key=value", to the keybase command run with it, on
+// top of the ones set on the CommandExecutor. It's used to hand secrets to a
+// command without putting them on its command line, where other local users
+// can read them. Executors that don't run the keybase binary can read the
+// variables with CommandEnvFrom.
+func WithCommandEnv(ctx context.Context, env ...string) context.Context {
+	env = append(CommandEnvFrom(ctx), env...)
+	return context.WithValue(ctx, commandEnvKey{}, env)
+}
+
+// CommandEnvFrom returns the environment variables set on ctx by
+// WithCommandEnv, if any
+func CommandEnvFrom(ctx context.Context) []string {
+	env, _ := ctx.Value(commandEnvKey{}).([]string)
+	return append([]string(nil), env...)
+}
+
+// cmdProcess is a Process backed by an *exec.Cmd
+type cmdProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+	stderr *tailBuffer
+}
+
+func (p *cmdProcess) Stdin() io.WriteCloser {
+	return p.stdin
+}
+
+func (p *cmdProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *cmdProcess) Stderr() string {
+	return p.stderr.String()
+}
+
+func (p *cmdProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+// stdiner is implemented by Processes whose stdin can be written to
+type stdiner interface {
+	Stdin() io.WriteCloser
+}
+
+// processStdin returns the stdin of proc, or nil if it doesn't have one
+func processStdin(proc Process) io.WriteCloser {
+	if s, ok := proc.(stdiner); ok {
+		return s.Stdin()
+	}
+	return nil
+}
+
+// stderrer is implemented by Processes that capture their stderr
+type stderrer interface {
+	Stderr() string
+}
+
+// processStderr returns what proc wrote to stderr, if it captures it
+func processStderr(proc Process) string {
+	if s, ok := proc.(stderrer); ok {
+		return s.Stderr()
+	}
+	return ""
+}
+
+// tailBuffer is an io.Writer that keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// executor returns the Executor used to run commands for k. If no Executor has
+// been set, the keybase binary at k.Path is used, with k's Home, SocketFile and
+// Env applied to every command, so that each Keybase talks to its own service.
+//
+// If k has a Recorder, the Executor is wrapped so that the commands are
+// recorded in its transcript.
+func (k *Keybase) executor() Executor {
+	e := k.Executor
+	if e == nil {
+		e = &CommandExecutor{
+			Path:       k.Path,
+			Home:       k.Home,
+			SocketFile: k.SocketFile,
+			Env:        k.Env,
+		}
+	}
+	if k.recorder != nil {
+		return &recordingExecutor{Executor: e, k: k, t: k.recorder.t}
+	}
+	return e
+}
@@ -0,0 +1,112 @@
+package keybase
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecutorIsolation(t *testing.T) {
+	alice := &Keybase{Path: "keybase", Home: "/home/alice", Env: []string{"KEYBASE_RUN_MODE=prod"}}
+	bob := &Keybase{Path: "keybase", Home: "/home/bob", SocketFile: "/run/bob.sock"}
+
+	tests := []struct {
+		k    *Keybase
+		want []string
+	}{
+		{alice, []string{"keybase", "--home", "/home/alice", "chat", "api-listen"}},
+		{bob, []string{"keybase", "--home", "/home/bob", "--socket-file", "/run/bob.sock", "chat", "api-listen"}},
+	}
+	for _, tt := range tests {
+		e, ok := tt.k.executor().(*CommandExecutor)
+		if !ok {
+			t.Fatalf("executor() = %T, want *CommandExecutor", tt.k.executor())
+		}
+		cmd := e.command(context.Background(), []string{"chat", "api-listen"})
+		if !reflect.DeepEqual(cmd.Args, tt.want) {
+			t.Errorf("Args = %q, want %q", cmd.Args, tt.want)
+		}
+	}
+
+	cmd := alice.executor().(*CommandExecutor).command(context.Background(), nil)
+	if got := cmd.Env[len(cmd.Env)-1]; got != "KEYBASE_RUN_MODE=prod" {
+		t.Errorf("last env var = %q, want %q", got, "KEYBASE_RUN_MODE=prod")
+	}
+
+	ctx := WithCommandEnv(context.Background(), "KEYBASE_PAPERKEY=secret")
+	cmd = alice.executor().(*CommandExecutor).command(ctx, []string{"oneshot"})
+	if got := cmd.Env[len(cmd.Env)-2:]; !reflect.DeepEqual(got, []string{"KEYBASE_RUN_MODE=prod", "KEYBASE_PAPERKEY=secret"}) {
+		t.Errorf("last env vars = %q", got)
+	}
+	if cmd := bob.executor().(*CommandExecutor).command(context.Background(), nil); cmd.Env != nil {
+		t.Errorf("Env = %q, want the inherited environment", cmd.Env)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	b.Write([]byte("hello "))
+	b.Write([]byte("world"))
+	if got := b.String(); got != "lo world" {
+		t.Errorf("got %q, want %q", got, "lo world")
+	}
+}
+
+func TestExecutorUsed(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"chat api":        `{"result":{}}`,
+		"team api":        `{"result":{}}`,
+		"kvstore api":     `{"result":{}}`,
+		"wallet api":      `{"result":{}}`,
+		"status -j":       `{"Username":"bot","LoggedIn":true}`,
+		"version -S -f s": "5.5.2\n",
+	}}
+	k := &Keybase{Executor: e}
+
+	// Every request goes through the Executor
+	if _, err := k.NewChat(Channel{Name: "bot"}).Send("hi"); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.NewTeam("team").MemberList(); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.NewKV("").Namespaces(); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.NewWallet().TxDetail("tx"); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.Status(); err != nil {
+		t.Error(err)
+	}
+	if _, err := k.version(context.Background()); err != nil {
+		t.Error(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		k.RunContext(ctx, func(ChatAPI) {})
+		close(done)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if calls, _ := e.history(); commandName(calls[len(calls)-1]) == "chat api-listen" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("api-listen wasn't started")
+		}
+	}
+	cancel()
+	<-done
+
+	var got []string
+	calls, _ := e.history()
+	for _, c := range calls {
+		got = append(got, commandName(c))
+	}
+	want := []string{"chat api", "team api", "kvstore api", "wallet api", "status", "version", "chat api-listen"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got commands %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package keybase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Hook observes the requests a Keybase sends to keybase, e.g. to log or trace
+// them. Hooks are called for every request to the chat, team, kvstore and
+// wallet APIs, and for every command run with Exec.
+type Hook interface {
+	// BeforeCall is called before the request is sent. The returned context
+	// is passed to AfterCall, and to the request itself, so it can carry
+	// things like trace spans.
+	BeforeCall(ctx context.Context, call *Call) context.Context
+
+	// AfterCall is called once the request has completed
+	AfterCall(ctx context.Context, call *Call)
+}
+
+// Call describes a request sent to keybase. Secrets are redacted from Command,
+// Request and Response according to the Keybase's Redaction policy.
+type Call struct {
+	API      string        // JSON API the request was sent to, e.g. "chat", or "" for other commands
+	Method   string        // JSON API method, e.g. "send", or command name, e.g. "status"
+	Command  []string      // Command line of requests that weren't sent to a JSON API
+	Request  []byte        // JSON API request
+	Response []byte        // Raw output of the request. Set before AfterCall
+	Err      error         // Error returned by the command or the JSON API. Set before AfterCall
+	Start    time.Time     // When the request was sent
+	Duration time.Duration // How long the request took. Set before AfterCall
+
+	Attempt        int    // Attempt number, starting at 1, if the request is retried
+	IdempotencyKey string // Set with WithIdempotencyKey
+}
+
+// Redaction selects the secrets that are removed from the Calls passed to
+// Hooks. Redacted values are replaced with "[REDACTED]". Paper keys are
+// redacted whatever the policy, unless WithoutPaperKeyRedaction is used.
+//
+// Oneshot passes the paper key to keybase in the environment, which is never
+// part of a Call, so it doesn't need redacting. Paper keys are only redacted
+// for callers that run `keybase oneshot --paperkey` themselves with Exec.
+type Redaction int
+
+// Secrets that can be redacted
+const (
+	RedactPaperKeys     Redaction = 1 << iota // Paper keys passed to `keybase oneshot --paperkey` with Exec
+	RedactKVValues                            // Entry values sent to or received from the kvstore API
+	RedactWalletAmounts                       // Amounts sent to or received from the wallet API
+
+	RedactNone Redaction = 0
+	RedactAll            = RedactPaperKeys | RedactKVValues | RedactWalletAmounts
+)
+
+// The value redacted secrets are replaced with
+const redacted = "[REDACTED]"
+
+// JSON fields holding secrets, keyed by the API they're redacted from
+var (
+	kvSecretFields     = map[string]bool{"entryValue": true}
+	walletSecretFields = map[string]bool{
+		"amount":             true,
+		"displayAmount":      true,
+		"sourceAmountMax":    true,
+		"sourceAmountActual": true,
+		"worth":              true,
+		"amountDescription":  true,
+	}
+)
+
+// WithHooks adds Hooks that observe every request k sends to keybase. Hooks
+// are called in the order they were added, and in reverse order after the
+// call.
+func WithHooks(hooks ...Hook) Option {
+	return func(k *Keybase) error {
+		k.hooks = append(k.hooks, hooks...)
+		return nil
+	}
+}
+
+// WithRedaction sets the secrets that are redacted from the Calls passed to
+// Hooks, on top of paper keys. Defaults to RedactPaperKeys.
+func WithRedaction(r Redaction) Option {
+	return func(k *Keybase) error {
+		k.redaction = r | RedactPaperKeys
+		return nil
+	}
+}
+
+// WithoutPaperKeyRedaction stops paper keys from being redacted from the Calls
+// passed to Hooks, whatever the Redaction policy. Only use it when debugging
+// Oneshot.
+func WithoutPaperKeyRedaction() Option {
+	return func(k *Keybase) error {
+		k.showPaperKeys = true
+		return nil
+	}
+}
+
+// LogHook is a Hook that logs every request, along with how long it took and
+// any error, to a Logger
+type LogHook struct {
+	Logger   Logger
+	Payloads bool // Also log requests and responses
+}
+
+// BeforeCall implements Hook
+func (h LogHook) BeforeCall(ctx context.Context, call *Call) context.Context {
+	return ctx
+}
+
+// AfterCall implements Hook
+func (h LogHook) AfterCall(ctx context.Context, call *Call) {
+	name := call.Method
+	if call.API != "" {
+		name = call.API + " " + call.Method
+	}
+	msg := "keybase: " + name + " took " + call.Duration.String()
+	if call.Err != nil {
+		msg += ": " + call.Err.Error()
+	}
+	if h.Payloads {
+		if call.Command != nil {
+			msg += "\n  command: " + strings.Join(call.Command, " ")
+		}
+		if call.Request != nil {
+			msg += "\n  request: " + string(call.Request)
+		}
+		msg += "\n  response: " + string(bytes.TrimSpace(call.Response))
+	}
+	h.Logger.Printf("%s", msg)
+}
+
+// observe runs a request, passing it through k's Hooks and Metrics
+func (k *Keybase) observe(ctx context.Context, call *Call, do func(context.Context) ([]byte, error)) ([]byte, error) {
+	if len(k.hooks) == 0 && k.metrics == nil {
+		return do(ctx)
+	}
+
+	if len(k.hooks) > 0 {
+		call.Command = k.redactCommand(call.Command)
+		call.Request = k.redactJSON(call.API, call.Request)
+	}
+	call.Attempt = attemptFrom(ctx)
+	call.IdempotencyKey = IdempotencyKeyFrom(ctx)
+	call.Start = time.Now()
+	for _, h := range k.hooks {
+		ctx = h.BeforeCall(ctx, call)
+	}
+
+	out, err := do(ctx)
+
+	call.Duration = time.Since(call.Start)
+	call.Err = err
+	if err == nil && call.API != "" {
+		call.Err = responseError(call.Method, out)
+	}
+	k.getMetrics().ObserveCall(call.API, call.Method, call.Duration, call.Err)
+	if len(k.hooks) > 0 {
+		call.Response = k.redactJSON(call.API, out)
+	}
+	for i := len(k.hooks) - 1; i >= 0; i-- {
+		k.hooks[i].AfterCall(ctx, call)
+	}
+	return out, err
+}
+
+// responseError returns the error in a JSON API response, if there is one
+func responseError(method string, out []byte) error {
+	var r struct {
+		Error *Error `json:"error"`
+	}
+	if json.Unmarshal(out, &r) != nil || r.Error == nil {
+		return nil
+	}
+	return newResponseError(method, *r.Error)
+}
+
+// redacts reports whether k redacts the given secrets. Paper keys are redacted
+// unless WithoutPaperKeyRedaction was used, even if k was created without New.
+func (k *Keybase) redacts(r Redaction) bool {
+	if r == RedactPaperKeys {
+		return !k.showPaperKeys
+	}
+	return k.redaction&r != 0
+}
+
+// redactCommand returns a copy of a command line with secrets redacted
+func (k *Keybase) redactCommand(command []string) []string {
+	if command == nil {
+		return nil
+	}
+	command = append([]string(nil), command...)
+	// Oneshot doesn't put the paper key on the command line, but callers
+	// that run oneshot with Exec might
+	if k.redacts(RedactPaperKeys) {
+		for i := 0; i+1 < len(command); i++ {
+			if command[i] == "--paperkey" {
+				command[i+1] = redacted
+			}
+		}
+	}
+	if k.redacts(RedactWalletAmounts) && len(command) >= 4 && command[0] == "wallet" && command[1] == "request" {
+		command[3] = redacted
+	}
+	return command
+}
+
+// redactJSON returns a copy of a JSON request or response to the given API
+// with secrets redacted. Payloads that aren't valid JSON are returned as is.
+func (k *Keybase) redactJSON(api string, payload []byte) []byte {
+	var fields map[string]bool
+	switch {
+	case api == "kvstore" && k.redacts(RedactKVValues):
+		fields = kvSecretFields
+	case api == "wallet" && k.redacts(RedactWalletAmounts):
+		fields = walletSecretFields
+	default:
+		return payload
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return payload
+	}
+	out, err := json.Marshal(redactFields(v, fields))
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// redactFields replaces the values of the given fields, at any depth of a
+// decoded JSON value
+func redactFields(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if fields[key] && value != nil {
+				v[key] = redacted
+			} else {
+				v[key] = redactFields(value, fields)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactFields(value, fields)
+		}
+	}
+	return v
+}
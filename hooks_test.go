@@ -0,0 +1,157 @@
+package keybase_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+type ctxKey struct{}
+
+// recordingHook remembers every Call it sees
+type recordingHook struct {
+	mu    sync.Mutex
+	calls []keybase.Call
+}
+
+func (h *recordingHook) BeforeCall(ctx context.Context, call *keybase.Call) context.Context {
+	return context.WithValue(ctx, ctxKey{}, call.Method)
+}
+
+func (h *recordingHook) AfterCall(ctx context.Context, call *keybase.Call) {
+	if ctx.Value(ctxKey{}) != call.Method {
+		panic("context from BeforeCall wasn't passed to AfterCall")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, *call)
+}
+
+// last returns the last Call for the given method
+func (h *recordingHook) last(t *testing.T, method string) keybase.Call {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.calls) - 1; i >= 0; i-- {
+		if h.calls[i].Method == method {
+			return h.calls[i]
+		}
+	}
+	t.Fatalf("no call to %s", method)
+	return keybase.Call{}
+}
+
+func TestHooks(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	srv.SetBalance("bot", 100)
+	hook := &recordingHook{}
+	k := srv.Keybase(t, keybase.WithHooks(hook))
+
+	if err := k.Oneshot("bot", "secret paper key words"); err != nil {
+		t.Fatal(err)
+	}
+	call := hook.last(t, "oneshot")
+	if strings.Contains(strings.Join(call.Command, " "), "secret") {
+		t.Errorf("paper key wasn't redacted: %q", call.Command)
+	}
+
+	if _, err := k.NewKV("").Put("ns", "key", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	call = hook.last(t, "put")
+	if call.API != "kvstore" || !strings.Contains(string(call.Request), "hunter2") || call.Err != nil {
+		t.Errorf("got %+v", call)
+	}
+
+	if _, err := k.NewChat(keybase.Channel{Name: "nope"}).Edit(42, "x"); err == nil {
+		t.Fatal("editing a missing message succeeded")
+	}
+	if call = hook.last(t, "edit"); call.Err == nil || call.Duration <= 0 {
+		t.Errorf("got %+v", call)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	srv.SetBalance("bot", 100)
+	hook := &recordingHook{}
+	k := srv.Keybase(t, keybase.WithHooks(hook), keybase.WithRedaction(keybase.RedactAll))
+
+	kv := k.NewKV("")
+	if _, err := kv.Put("ns", "key", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get("ns", "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"put", "get"} {
+		call := hook.last(t, method)
+		if strings.Contains(string(call.Request)+string(call.Response), "hunter2") {
+			t.Errorf("%s: KV value wasn't redacted: %s %s", method, call.Request, call.Response)
+		}
+	}
+	if call := hook.last(t, "get"); !strings.Contains(string(call.Response), `"entryKey":"key"`) {
+		t.Errorf("too much was redacted: %s", call.Response)
+	}
+
+	if _, err := k.NewWallet().SendXLM("alice", "12.5"); err != nil {
+		t.Fatal(err)
+	}
+	call := hook.last(t, "send")
+	if strings.Contains(string(call.Request)+string(call.Response), "12.5") {
+		t.Errorf("amount wasn't redacted: %s %s", call.Request, call.Response)
+	}
+}
+
+func TestPaperKeyRedaction(t *testing.T) {
+	const paperkey = "secret paper key"
+	srv := keybasetest.NewServer("bot")
+	for i, opts := range [][]keybase.Option{
+		nil,
+		{keybase.WithRedaction(keybase.RedactKVValues)},
+		{keybase.WithRedaction(keybase.RedactNone)},
+		{keybase.WithoutPaperKeyRedaction()},
+	} {
+		// Oneshot passes the paper key in the environment, so it shows up
+		// in neither the Calls nor the transcript, whatever the policy
+		hook := &recordingHook{}
+		var transcript bytes.Buffer
+		k := srv.Keybase(t, append(opts, keybase.WithHooks(hook), keybase.WithRecorder(keybase.NewRecorder(&transcript)))...)
+		if err := k.Oneshot("bot", paperkey); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		hook.last(t, "oneshot")
+		for _, call := range hook.calls {
+			if s := fmt.Sprintf("%q %s %s %v", call.Command, call.Request, call.Response, call.Err); strings.Contains(s, paperkey) {
+				t.Errorf("%d: paper key in %s call: %s", i, call.Method, s)
+			}
+		}
+		if strings.Contains(transcript.String(), paperkey) {
+			t.Errorf("%d: paper key in transcript: %s", i, transcript.String())
+		}
+	}
+
+	// Callers that run oneshot with Exec have the paper key redacted from
+	// the command line, unless they turn that off
+	for i, tt := range []struct {
+		opts    []keybase.Option
+		visible bool
+	}{
+		{[]keybase.Option{keybase.WithRedaction(keybase.RedactNone)}, false},
+		{[]keybase.Option{keybase.WithoutPaperKeyRedaction()}, true},
+	} {
+		hook := &recordingHook{}
+		k := srv.Keybase(t, append(tt.opts, keybase.WithHooks(hook))...)
+		k.Exec("oneshot", "--paperkey", paperkey)
+		call := hook.last(t, "oneshot")
+		if visible := strings.Contains(strings.Join(call.Command, " "), paperkey); visible != tt.visible {
+			t.Errorf("%d: paper key visible = %v, want %v: %q", i, visible, tt.visible, call.Command)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package keybase
+
+import (
+	"context"
+	"time"
+)
+
+// The interfaces below describe the methods of Keybase, Chat, Team, KV and
+// Wallet, so that code using them can depend on an interface and be tested
+// with a fake. Together they mirror the full method sets of those types: the
+// types are checked against them at compile time, and TestInterfaces checks
+// that no method of the types is missing from them.
+var (
+	_ Client       = (*Keybase)(nil)
+	_ Runner       = (*Keybase)(nil)
+	_ EventRunner  = (*Keybase)(nil)
+	_ Clients      = clients{}
+	_ ChatClient   = Chat{}
+	_ Pager        = (*ChatAPI)(nil)
+	_ TeamClient   = Team{}
+	_ KVClient     = KV{}
+	_ WalletClient = Wallet{}
+)
+
+// Client is the interface implemented by *Keybase for the requests that
+// aren't made through a Chat, Team, KV or Wallet. Those are handed out by
+// Clients.
+type Client interface {
+	AdvertiseCommand(advertisement BotAdvertisement) (ChatAPI, error)
+	AdvertiseCommandContext(ctx context.Context, advertisement BotAdvertisement) (ChatAPI, error)
+	AdvertiseCommands(advertisements []BotAdvertisement) (ChatAPI, error)
+	AdvertiseCommandsContext(ctx context.Context, advertisements []BotAdvertisement) (ChatAPI, error)
+	ChatList(opts ...Channel) (ChatAPI, error)
+	ChatListContext(ctx context.Context, opts ...Channel) (ChatAPI, error)
+	ChatListResult(opts ...Channel) (ListResult, error)
+	ChatListResultContext(ctx context.Context, opts ...Channel) (ListResult, error)
+	ClearCommands() (ChatAPI, error)
+	ClearCommandsContext(ctx context.Context) (ChatAPI, error)
+	SearchInbox(query string) (ChatAPI, error)
+	SearchInboxContext(ctx context.Context, query string) (ChatAPI, error)
+	CreateTeam(name string) (TeamAPI, error)
+	CreateTeamContext(ctx context.Context, name string) (TeamAPI, error)
+	ListUserMemberships(user string) (TeamAPI, error)
+	ListUserMembershipsContext(ctx context.Context, user string) (TeamAPI, error)
+	UserLookup(users ...string) (UserAPI, error)
+	UserLookupContext(ctx context.Context, users ...string) (UserAPI, error)
+	UserCard(user string) (UserCardAPI, error)
+	UserCardContext(ctx context.Context, user string) (UserCardAPI, error)
+
+	Exec(command ...string) ([]byte, error)
+	ExecContext(ctx context.Context, command ...string) ([]byte, error)
+	Oneshot(username, paperkey string) error
+	OneshotContext(ctx context.Context, username, paperkey string) error
+	Logout() error
+	LogoutContext(ctx context.Context) error
+	Status() (Status, error)
+	StatusContext(ctx context.Context) (Status, error)
+	HealthCheck(ctx context.Context) error
+	SemVer() (SemVer, error)
+	Supports(api, method string) bool
+	RateLimits() []RateLimit
+	StartService(ctx context.Context) error
+	StopService(ctx context.Context) error
+	Close() error
+}
+
+// Clients hands out the clients for chat channels, teams, KV stores and
+// wallets. Use NewClients to get one for a Keybase, or implement it with fakes.
+type Clients interface {
+	NewChat(channel Channel) ChatClient
+	NewTeam(name string) TeamClient
+	NewKV(team string) KVClient
+	NewWallet() WalletClient
+}
+
+// NewClients returns the Clients of k, which hands out the same Chat, Team, KV
+// and Wallet as k's own methods
+func NewClients(k *Keybase) Clients {
+	return clients{k: k}
+}
+
+// clients is the Clients returned by NewClients
+type clients struct {
+	k *Keybase
+}
+
+func (c clients) NewChat(channel Channel) ChatClient { return c.k.NewChat(channel) }
+func (c clients) NewTeam(name string) TeamClient     { return c.k.NewTeam(name) }
+func (c clients) NewKV(team string) KVClient         { return c.k.NewKV(team) }
+func (c clients) NewWallet() WalletClient            { return c.k.NewWallet() }
+
+// Runner is the interface implemented by *Keybase for receiving messages
+type Runner interface {
+	Run(handler func(ChatAPI), options ...RunOptions)
+	RunContext(ctx context.Context, handler func(ChatAPI), options ...RunOptions) error
+}
+
+// EventRunner is the interface implemented by *Keybase for receiving Events
+type EventRunner interface {
+	RunEvents(ctx context.Context, handler func(Event), options ...RunOptions) error
+}
+
+// ChatClient is the interface implemented by Chat
+type ChatClient interface {
+	Send(message ...string) (ChatAPI, error)
+	SendContext(ctx context.Context, message ...string) (ChatAPI, error)
+	SendResult(message ...string) (SendResult, error)
+	SendResultContext(ctx context.Context, message ...string) (SendResult, error)
+	SendEphemeral(duration time.Duration, message ...string) (ChatAPI, error)
+	SendEphemeralContext(ctx context.Context, duration time.Duration, message ...string) (ChatAPI, error)
+	SendEphemeralResult(duration time.Duration, message ...string) (SendResult, error)
+	SendEphemeralResultContext(ctx context.Context, duration time.Duration, message ...string) (SendResult, error)
+	Reply(replyTo int, message ...string) (ChatAPI, error)
+	ReplyContext(ctx context.Context, replyTo int, message ...string) (ChatAPI, error)
+	ReplyResult(replyTo int, message ...string) (SendResult, error)
+	ReplyResultContext(ctx context.Context, replyTo int, message ...string) (SendResult, error)
+	Edit(messageID int, message ...string) (ChatAPI, error)
+	EditContext(ctx context.Context, messageID int, message ...string) (ChatAPI, error)
+	EditResult(messageID int, message ...string) (SendResult, error)
+	EditResultContext(ctx context.Context, messageID int, message ...string) (SendResult, error)
+	React(messageID int, reaction string) (ChatAPI, error)
+	ReactContext(ctx context.Context, messageID int, reaction string) (ChatAPI, error)
+	ReactResult(messageID int, reaction string) (SendResult, error)
+	ReactResultContext(ctx context.Context, messageID int, reaction string) (SendResult, error)
+	Delete(messageID int) (ChatAPI, error)
+	DeleteContext(ctx context.Context, messageID int) (ChatAPI, error)
+	DeleteResult(messageID int) (SendResult, error)
+	DeleteResultContext(ctx context.Context, messageID int) (SendResult, error)
+	Read(count ...int) (*ChatAPI, error)
+	ReadContext(ctx context.Context, count ...int) (*ChatAPI, error)
+	ReadResult(count ...int) (ReadResult, error)
+	ReadResultContext(ctx context.Context, count ...int) (ReadResult, error)
+	ReadMessage(messageID int) (*ChatAPI, error)
+	ReadMessageContext(ctx context.Context, messageID int) (*ChatAPI, error)
+	ReadMessageResult(messageID int) (ReadResult, error)
+	ReadMessageResultContext(ctx context.Context, messageID int) (ReadResult, error)
+	Upload(title string, filepath string) (ChatAPI, error)
+	UploadContext(ctx context.Context, title string, filepath string) (ChatAPI, error)
+	UploadResult(title string, filepath string) (SendResult, error)
+	UploadResultContext(ctx context.Context, title string, filepath string) (SendResult, error)
+	Download(messageID int, filepath string) (ChatAPI, error)
+	DownloadContext(ctx context.Context, messageID int, filepath string) (ChatAPI, error)
+	DownloadResult(messageID int, filepath string) (DownloadResult, error)
+	DownloadResultContext(ctx context.Context, messageID int, filepath string) (DownloadResult, error)
+	LoadFlip(messageID int, conversationID string, flipConversationID string, gameID string) (ChatAPI, error)
+	LoadFlipContext(ctx context.Context, messageID int, conversationID string, flipConversationID string, gameID string) (ChatAPI, error)
+	LoadFlipResult(messageID int, conversationID string, flipConversationID string, gameID string) (FlipResult, error)
+	LoadFlipResultContext(ctx context.Context, messageID int, conversationID string, flipConversationID string, gameID string) (FlipResult, error)
+	Pin(messageID int) (ChatAPI, error)
+	PinContext(ctx context.Context, messageID int) (ChatAPI, error)
+	Unpin() (ChatAPI, error)
+	UnpinContext(ctx context.Context) (ChatAPI, error)
+	Mark(messageID int) (ChatAPI, error)
+	MarkContext(ctx context.Context, messageID int) (ChatAPI, error)
+	AddEmoji(alias string, filepath string) (ChatAPI, error)
+	AddEmojiContext(ctx context.Context, alias string, filepath string) (ChatAPI, error)
+	AddBotMember(username string, role string) (ChatAPI, error)
+	AddBotMemberContext(ctx context.Context, username string, role string) (ChatAPI, error)
+	RemoveBotMember(username string) (ChatAPI, error)
+	RemoveBotMemberContext(ctx context.Context, username string) (ChatAPI, error)
+}
+
+// Pager is the interface implemented by *ChatAPI, for paging through the
+// messages returned by Chat.Read
+type Pager interface {
+	Next(count ...int) (*ChatAPI, error)
+	NextContext(ctx context.Context, count ...int) (*ChatAPI, error)
+	NextResult(count ...int) (ReadResult, error)
+	NextResultContext(ctx context.Context, count ...int) (ReadResult, error)
+	Previous(count ...int) (*ChatAPI, error)
+	PreviousContext(ctx context.Context, count ...int) (*ChatAPI, error)
+	PreviousResult(count ...int) (ReadResult, error)
+	PreviousResultContext(ctx context.Context, count ...int) (ReadResult, error)
+}
+
+// TeamClient is the interface implemented by Team
+type TeamClient interface {
+	AddAdmins(users ...string) (TeamAPI, error)
+	AddAdminsContext(ctx context.Context, users ...string) (TeamAPI, error)
+	AddOwners(users ...string) (TeamAPI, error)
+	AddOwnersContext(ctx context.Context, users ...string) (TeamAPI, error)
+	AddReaders(users ...string) (TeamAPI, error)
+	AddReadersContext(ctx context.Context, users ...string) (TeamAPI, error)
+	AddWriters(users ...string) (TeamAPI, error)
+	AddWritersContext(ctx context.Context, users ...string) (TeamAPI, error)
+	AddUser(user, role string) (TeamAPI, error)
+	AddUserContext(ctx context.Context, user, role string) (TeamAPI, error)
+	RemoveUser(user string) (TeamAPI, error)
+	RemoveUserContext(ctx context.Context, user string) (TeamAPI, error)
+	CreateSubteam(name string) (TeamAPI, error)
+	CreateSubteamContext(ctx context.Context, name string) (TeamAPI, error)
+	MemberList() (TeamAPI, error)
+	MemberListContext(ctx context.Context) (TeamAPI, error)
+}
+
+// KVClient is the interface implemented by KV
+type KVClient interface {
+	Namespaces() (KVAPI, error)
+	NamespacesContext(ctx context.Context) (KVAPI, error)
+	Keys(namespace string) (KVAPI, error)
+	KeysContext(ctx context.Context, namespace string) (KVAPI, error)
+	Get(namespace string, key string, revision ...uint) (KVAPI, error)
+	GetContext(ctx context.Context, namespace string, key string, revision ...uint) (KVAPI, error)
+	Put(namespace string, key string, value string, revision ...uint) (KVAPI, error)
+	PutContext(ctx context.Context, namespace string, key string, value string, revision ...uint) (KVAPI, error)
+	Delete(namespace string, key string, revision ...uint) (KVAPI, error)
+	DeleteContext(ctx context.Context, namespace string, key string, revision ...uint) (KVAPI, error)
+}
+
+// WalletClient is the interface implemented by Wallet
+type WalletClient interface {
+	Send(recipient string, amount string, currency string, message ...string) (WalletAPI, error)
+	SendContext(ctx context.Context, recipient string, amount string, currency string, message ...string) (WalletAPI, error)
+	SendXLM(recipient string, amount string, message ...string) (WalletAPI, error)
+	SendXLMContext(ctx context.Context, recipient string, amount string, message ...string) (WalletAPI, error)
+	RequestPayment(user string, amount float64, memo ...string) error
+	RequestPaymentContext(ctx context.Context, user string, amount float64, memo ...string) error
+	CancelRequest(requestID string) error
+	CancelRequestContext(ctx context.Context, requestID string) error
+	StellarAddress(user string) (string, error)
+	StellarAddressContext(ctx context.Context, user string) (string, error)
+	StellarUser(wallet string) (string, error)
+	StellarUserContext(ctx context.Context, wallet string) (string, error)
+	TxDetail(txid string) (WalletAPI, error)
+	TxDetailContext(ctx context.Context, txid string) (WalletAPI, error)
+}
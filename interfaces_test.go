@@ -0,0 +1,37 @@
+package keybase
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterfaces(t *testing.T) {
+	for _, tt := range []struct {
+		typ    reflect.Type
+		ifaces []reflect.Type
+	}{
+		{reflect.TypeOf(&Keybase{}), []reflect.Type{
+			reflect.TypeOf((*Client)(nil)).Elem(),
+			reflect.TypeOf((*Clients)(nil)).Elem(),
+			reflect.TypeOf((*Runner)(nil)).Elem(),
+			reflect.TypeOf((*EventRunner)(nil)).Elem(),
+		}},
+		{reflect.TypeOf(Chat{}), []reflect.Type{reflect.TypeOf((*ChatClient)(nil)).Elem()}},
+		{reflect.TypeOf(Team{}), []reflect.Type{reflect.TypeOf((*TeamClient)(nil)).Elem()}},
+		{reflect.TypeOf(KV{}), []reflect.Type{reflect.TypeOf((*KVClient)(nil)).Elem()}},
+		{reflect.TypeOf(Wallet{}), []reflect.Type{reflect.TypeOf((*WalletClient)(nil)).Elem()}},
+	} {
+		for i := 0; i < tt.typ.NumMethod(); i++ {
+			name := tt.typ.Method(i).Name
+			found := false
+			for _, iface := range tt.ifaces {
+				if _, ok := iface.MethodByName(name); ok {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s.%s is missing from %v", tt.typ, name, tt.ifaces)
+			}
+		}
+	}
+}
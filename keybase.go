@@ -1,6 +1,7 @@
 package keybase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
@@ -19,23 +20,84 @@ const (
 	CHAT string = "chat"
 )
 
+// New returns a new Keybase configured with the given options. It returns an
+// error if the keybase executable can't be found, or if the keybase service
+// isn't running. Use WithService to have New start the service.
+func New(opts ...Option) (*Keybase, error) {
+	k := &Keybase{Path: "keybase", Sessions: true, redaction: RedactPaperKeys}
+	for _, opt := range opts {
+		if err := opt(k); err != nil {
+			return nil, err
+		}
+	}
+
+	if k.Executor == nil {
+		if _, err := exec.LookPath(k.Path); err != nil {
+			return nil, fmt.Errorf("keybase: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	if k.startService {
+		timeout := k.Timeout
+		if timeout <= 0 {
+			timeout = serviceStartTimeout
+		}
+		startCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := k.StartService(startCtx)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := k.StatusContext(ctx)
+	if err != nil {
+		k.Close()
+		return nil, err
+	}
+	if !s.Service.Running {
+		k.Close()
+		return nil, ErrServiceNotRunning
+	}
+	version, err := k.version(ctx)
+	if err != nil {
+		k.Close()
+		return nil, err
+	}
+
+	k.Version = version
+	k.setStatus(s)
+	return k, nil
+}
+
 // NewKeybase returns a new Keybase. Optionally, you can pass a string containing the path to the Keybase executable as the first argument.
+// Unlike New, NewKeybase doesn't report whether the keybase executable could be run.
 func NewKeybase(path ...string) *Keybase {
-	k := &Keybase{}
+	k := &Keybase{Sessions: true}
 	if len(path) < 1 {
 		k.Path = "keybase"
 	} else {
 		k.Path = path[0]
 	}
 
-	s := k.status()
-	k.Version = k.version()
+	ctx := context.Background()
+	s, _ := k.StatusContext(ctx)
+	k.Version, _ = k.version(ctx)
+	k.setStatus(s)
+	return k
+}
+
+// setStatus updates k's cached user information from the given status
+func (k *Keybase) setStatus(s Status) {
 	k.LoggedIn = s.LoggedIn
 	if k.LoggedIn {
 		k.Username = s.Username
 		k.Device = s.Device.Name
+	} else {
+		k.Username = ""
+		k.Device = ""
 	}
-	return k
 }
 
 // NewBotCommand returns a new BotCommand instance
@@ -64,11 +126,129 @@ func NewBotCommandExtendedDescription(title, desktopBody, mobileBody string) Bot
 
 // Exec executes the given Keybase command
 func (k *Keybase) Exec(command ...string) ([]byte, error) {
-	out, err := exec.Command(k.Path, command...).Output()
+	return k.ExecContext(context.Background(), command...)
+}
+
+// ExecContext executes the given Keybase command. If ctx is done before the
+// command completes, the command is killed and ctx.Err() is returned. Any other
+// failure is returned as an *APIError. If k has a Timeout and ctx has no
+// deadline, the Timeout is applied.
+func (k *Keybase) ExecContext(ctx context.Context, command ...string) ([]byte, error) {
+	call := &Call{Method: commandName(command), Command: command}
+	return k.observe(ctx, call, func(ctx context.Context) ([]byte, error) {
+		return k.exec(ctx, command)
+	})
+}
+
+// exec runs a keybase command for ExecContext, without calling k's Hooks
+func (k *Keybase) exec(ctx context.Context, command []string) ([]byte, error) {
+	ctx, cancel := k.withTimeout(ctx)
+	defer cancel()
+
+	type output struct {
+		out []byte
+		err error
+	}
+
+	// The Executor is expected to kill the command when ctx is done, but
+	// don't rely on it to return promptly
+	done := make(chan output, 1)
+	go func() {
+		out, err := k.executor().Output(ctx, command...)
+		done <- output{out, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			if ctx.Err() != nil {
+				return []byte{}, ctx.Err()
+			}
+			return []byte{}, newExecError(command, o.err)
+		}
+		return o.out, nil
+	case <-ctx.Done():
+		return []byte{}, ctx.Err()
+	}
+}
+
+// withTimeout applies k's default Timeout to ctx, unless ctx already has a
+// deadline
+func (k *Keybase) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || k.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, k.Timeout)
+}
+
+// lifetime returns a context that is cancelled when k is closed
+func (k *Keybase) lifetime() context.Context {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.ctx == nil {
+		k.ctx, k.cancel = context.WithCancel(context.Background())
+	}
+	return k.ctx
+}
+
+// Close stops any listeners started by Run, any API sessions, and the service
+// started by StartService, if there is one. k can still be used afterwards, in
+// which case new sessions and listeners are started as needed.
+func (k *Keybase) Close() error {
+	k.mu.Lock()
+	if k.cancel != nil {
+		k.cancel()
+	}
+	k.ctx, k.cancel = nil, nil
+	k.mu.Unlock()
+
+	k.closeSessions()
+
+	ctx, cancel := context.WithTimeout(context.Background(), serviceStopTimeout)
+	defer cancel()
+	return k.StopService(ctx)
+}
+
+// Oneshot logs in as the given user with a paper key, without provisioning a
+// new device. This is the recommended way to log in bots. The cached Username,
+// LoggedIn and Device fields are refreshed afterwards.
+func (k *Keybase) Oneshot(username, paperkey string) error {
+	return k.OneshotContext(context.Background(), username, paperkey)
+}
+
+// OneshotContext is like Oneshot, but aborts the request when ctx is done
+func (k *Keybase) OneshotContext(ctx context.Context, username, paperkey string) error {
+	// The credentials are passed in the environment of `keybase oneshot`
+	// rather than as flags, which any local user could read with ps
+	ctx = WithCommandEnv(ctx, "KEYBASE_USERNAME="+username, "KEYBASE_PAPERKEY="+paperkey)
+	if _, err := k.ExecContext(ctx, "oneshot"); err != nil {
+		return err
+	}
+	return k.refresh(ctx)
+}
+
+// Logout logs out the current user. The cached Username, LoggedIn and Device
+// fields are refreshed afterwards.
+func (k *Keybase) Logout() error {
+	return k.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout, but aborts the request when ctx is done
+func (k *Keybase) LogoutContext(ctx context.Context) error {
+	if _, err := k.ExecContext(ctx, "logout", "--force"); err != nil {
+		return err
+	}
+	return k.refresh(ctx)
+}
+
+// refresh updates k's cached user information from `keybase status`
+func (k *Keybase) refresh(ctx context.Context) error {
+	s, err := k.StatusContext(ctx)
 	if err != nil {
-		return []byte{}, err
+		return err
 	}
-	return out, nil
+	k.setStatus(s)
+	return nil
 }
 
 // NewChat returns a new Chat instance
@@ -102,36 +282,74 @@ func (k *Keybase) NewWallet() Wallet {
 	}
 }
 
-// status returns the results of the `keybase status` command, which includes
-// information about the client, and the currently logged-in Keybase user.
-func (k *Keybase) status() status {
-	cmdOut, err := k.Exec("status", "-j")
+// Status returns the results of the `keybase status` command, which includes
+// information about the client, the service, and the currently logged-in
+// Keybase user.
+func (k *Keybase) Status() (Status, error) {
+	return k.StatusContext(context.Background())
+}
+
+// StatusContext is like Status, but aborts the request when ctx is done
+func (k *Keybase) StatusContext(ctx context.Context) (Status, error) {
+	cmdOut, err := k.execRetry(ctx, "status", "-j")
 	if err != nil {
-		return status{}
+		return Status{}, err
+	}
+
+	var s Status
+	if err := json.Unmarshal(cmdOut, &s); err != nil {
+		return Status{}, decodeError("status", err)
 	}
 
-	var s status
-	json.Unmarshal(cmdOut, &s)
+	return s, nil
+}
+
+// HealthCheck verifies that the keybase service is running, that a user is
+// logged in, and that the chat API is answering requests. It's suitable for
+// use as a readiness probe.
+func (k *Keybase) HealthCheck(ctx context.Context) error {
+	s, err := k.StatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !s.Service.Running {
+		return ErrServiceNotRunning
+	}
+	if !s.LoggedIn {
+		return ErrNotLoggedIn
+	}
 
-	return s
+	// Listing the user's own conversation is about the cheapest chat API
+	// request there is
+	_, err = k.ChatListContext(ctx, Channel{Name: s.Username, MembersType: USER})
+	if err != nil {
+		return fmt.Errorf("keybase: chat api: %w", err)
+	}
+	return nil
 }
 
-// version returns the version string of the client.
-func (k *Keybase) version() string {
-	cmdOut, err := k.Exec("version", "-S", "-f", "s")
+// version returns the version string of the client, which can be parsed with
+// ParseVersion.
+func (k *Keybase) version(ctx context.Context) (string, error) {
+	cmdOut, err := k.execRetry(ctx, "version", "-S", "-f", "s")
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	return string(cmdOut)
+	return strings.TrimSpace(string(cmdOut)), nil
 }
 
 // UserLookup pulls information about users.
 // The following fields are currently returned: basics, profile, proofs_summary, devices -- See https://keybase.io/docs/api/1.0/call/user/lookup for more info.
 func (k *Keybase) UserLookup(users ...string) (UserAPI, error) {
+	return k.UserLookupContext(context.Background(), users...)
+}
+
+// UserLookupContext is like UserLookup, but aborts the request when ctx is done
+func (k *Keybase) UserLookupContext(ctx context.Context, users ...string) (UserAPI, error) {
 	var fields = []string{"basics", "profile", "proofs_summary", "devices"}
 
-	cmdOut, err := k.Exec("apicall", "--arg", fmt.Sprintf("usernames=%s", strings.Join(users, ",")), "--arg", fmt.Sprintf("fields=%s", strings.Join(fields, ",")), "user/lookup")
+	cmdOut, err := k.execRetry(ctx, "apicall", "--arg", fmt.Sprintf("usernames=%s", strings.Join(users, ",")), "--arg", fmt.Sprintf("fields=%s", strings.Join(fields, ",")), "user/lookup")
 	if err != nil {
 		return UserAPI{}, err
 	}
@@ -146,7 +364,12 @@ func (k *Keybase) UserLookup(users ...string) (UserAPI, error) {
 
 // UserCard pulls the information that is typically displayed when you open a user's profile.
 func (k *Keybase) UserCard(user string) (UserCardAPI, error) {
-	cmdOut, err := k.Exec("apicall", "--arg", "username="+user, "user/card")
+	return k.UserCardContext(context.Background(), user)
+}
+
+// UserCardContext is like UserCard, but aborts the request when ctx is done
+func (k *Keybase) UserCardContext(ctx context.Context, user string) (UserCardAPI, error) {
+	cmdOut, err := k.execRetry(ctx, "apicall", "--arg", "username="+user, "user/card")
 	if err != nil {
 		return UserCardAPI{}, err
 	}
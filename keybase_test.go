@@ -0,0 +1,215 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExecutor is an Executor that returns canned output for each command.
+// Processes it starts run until their context is done, or until `ctl stop`
+// is run.
+type fakeExecutor struct {
+	outputs map[string]string // keyed by the space-separated command, or its name
+	stderr  string            // Returned by the Stderr method of the processes it starts
+
+	mu    sync.Mutex
+	calls [][]string // Commands that were run or started
+	envs  [][]string // Set with WithCommandEnv, for each call
+	stops []context.CancelFunc
+}
+
+// history returns the commands that were run, and their environments
+func (e *fakeExecutor) history() (calls, envs [][]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([][]string(nil), e.calls...), append([][]string(nil), e.envs...)
+}
+
+func (e *fakeExecutor) Output(ctx context.Context, args ...string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, args)
+	e.envs = append(e.envs, CommandEnvFrom(ctx))
+	out, ok := e.outputs[strings.Join(args, " ")]
+	if !ok {
+		out, ok = e.outputs[commandName(args)]
+	}
+	if !ok {
+		return nil, errors.New("exit status 1")
+	}
+	if strings.Join(args, " ") == "ctl stop" {
+		for _, stop := range e.stops {
+			stop()
+		}
+	}
+	return []byte(out), nil
+}
+
+func (e *fakeExecutor) Start(ctx context.Context, args ...string) (Process, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	e.calls = append(e.calls, args)
+	e.envs = append(e.envs, CommandEnvFrom(ctx))
+	e.stops = append(e.stops, cancel)
+	e.mu.Unlock()
+	r, w := io.Pipe()
+	go func() {
+		<-ctx.Done()
+		w.Close()
+	}()
+	return &fakeProcess{ctx: ctx, stdout: r, stderr: e.stderr}, nil
+}
+
+// fakeProcess is a Process that produces no output and runs until its context
+// is done
+type fakeProcess struct {
+	ctx    context.Context
+	stdout io.Reader
+	stderr string
+}
+
+func (p *fakeProcess) Stdin() io.WriteCloser { return nopWriteCloser{} }
+func (p *fakeProcess) Stdout() io.Reader     { return p.stdout }
+func (p *fakeProcess) Stderr() string        { return p.stderr }
+func (p *fakeProcess) Wait() error {
+	<-p.ctx.Done()
+	return p.ctx.Err()
+}
+
+type nopWriteCloser struct{}
+
+func (nopWriteCloser) Write(b []byte) (int, error) { return len(b), nil }
+func (nopWriteCloser) Close() error                { return nil }
+
+func TestNew(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"status -j":       `{"Username":"bot","LoggedIn":true,"Device":{"name":"box"},"Service":{"Running":true}}`,
+		"version -S -f s": "5.5.0\n",
+	}}
+
+	k, err := New(WithExecutor(e), WithTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Username != "bot" || !k.LoggedIn || k.Device != "box" {
+		t.Errorf("unexpected status: %+v", k)
+	}
+	if k.Timeout != time.Second {
+		t.Errorf("Timeout = %v, want %v", k.Timeout, time.Second)
+	}
+}
+
+func TestNewServiceNotRunning(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"status -j":       `{"LoggedIn":false,"Service":{"Running":false}}`,
+		"version -S -f s": "5.5.0\n",
+	}}
+
+	if _, err := New(WithExecutor(e)); !errors.Is(err, ErrServiceNotRunning) {
+		t.Errorf("New() error = %v, want %v", err, ErrServiceNotRunning)
+	}
+}
+
+func TestNewMissingBinary(t *testing.T) {
+	if _, err := New(WithPath("/nonexistent/keybase")); err == nil {
+		t.Error("New() with missing binary succeeded")
+	}
+}
+
+func TestNewKeybaseCompat(t *testing.T) {
+	k := NewKeybase("/nonexistent/keybase")
+	if k == nil || k.Path != "/nonexistent/keybase" {
+		t.Fatalf("NewKeybase() = %+v", k)
+	}
+	if k.LoggedIn {
+		t.Error("LoggedIn = true for a missing binary")
+	}
+}
+
+func TestOneshotLogout(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"oneshot":   ``,
+		"status -j": `{"Username":"bot","LoggedIn":true,"Device":{"name":"oneshot"}}`,
+	}}
+	k := &Keybase{Executor: e}
+
+	if err := k.Oneshot("bot", "one two three"); err != nil {
+		t.Fatal(err)
+	}
+	// The paper key mustn't show up on the command line
+	wantEnv := []string{"KEYBASE_USERNAME=bot", "KEYBASE_PAPERKEY=one two three"}
+	if calls, envs := e.history(); !reflect.DeepEqual(calls[0], []string{"oneshot"}) || !reflect.DeepEqual(envs[0], wantEnv) {
+		t.Errorf("ran %q with env %q", calls[0], envs[0])
+	}
+	if !k.LoggedIn || k.Username != "bot" || k.Device != "oneshot" {
+		t.Errorf("after Oneshot: %+v", k)
+	}
+
+	e.outputs["logout --force"] = ``
+	e.outputs["status -j"] = `{"LoggedIn":false}`
+	if err := k.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if k.LoggedIn || k.Username != "" || k.Device != "" {
+		t.Errorf("after Logout: %+v", k)
+	}
+}
+
+func TestCloseStopsRun(t *testing.T) {
+	k := &Keybase{Executor: &fakeExecutor{}}
+
+	started := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		k.Run(func(m ChatAPI) {
+			if m.Type == ListenerStarted {
+				select {
+				case started <- struct{}{}:
+				default:
+				}
+			}
+		}, RunOptions{ListenerEvents: true})
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("api-listen wasn't started")
+	}
+	k.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after Close")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		status string
+		chat   string
+		want   error
+	}{
+		{`{"Service":{"Running":false}}`, `{}`, ErrServiceNotRunning},
+		{`{"LoggedIn":false,"Service":{"Running":true}}`, `{}`, ErrNotLoggedIn},
+		{`{"Username":"bot","LoggedIn":true,"Service":{"Running":true}}`, `{"error":{"code":201,"message":"login required"}}`, ErrNotLoggedIn},
+		{`{"Username":"bot","LoggedIn":true,"Service":{"Running":true}}`, `{"result":{"conversations":[]}}`, nil},
+	}
+	for _, tt := range tests {
+		k := &Keybase{Executor: &fakeExecutor{outputs: map[string]string{
+			"status -j": tt.status,
+			"chat api":  tt.chat,
+		}}}
+		err := k.HealthCheck(context.Background())
+		if tt.want == nil && err != nil || !errors.Is(err, tt.want) {
+			t.Errorf("HealthCheck() with status %s = %v, want %v", tt.status, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package keybasetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"samhofi.us/x/keybase"
+)
+
+// Message is a chat message stored by a Server
+type Message struct {
+	ID             int
+	ConversationID string
+	Channel        keybase.Channel
+	Sender         string
+	Type           string // "text", "edit", "reaction", "delete" or "attachment"
+	Body           string // Text, edit or reaction body, or attachment title
+	ReplyTo        int    // Message replied to by a text message
+	Target         int    // Message targeted by an edit, reaction or deletion
+	SentAt         time.Time
+	Exploding      time.Duration // Lifetime of an exploding message, or 0
+	Deleted        bool
+}
+
+// conversation holds the messages of a single channel
+type conversation struct {
+	id       string
+	channel  keybase.Channel
+	messages []*Message
+	activeAt time.Time
+	pinned   int
+}
+
+// chatOptions holds the options of a chat API request
+type chatOptions struct {
+	Channel *keybase.Channel `json:"channel"`
+	Message *struct {
+		Body string `json:"body"`
+	} `json:"message"`
+	MessageID  int `json:"message_id"`
+	Pagination *struct {
+		Num int `json:"num"`
+	} `json:"pagination"`
+	Filename          string `json:"filename"`
+	Title             string `json:"title"`
+	Output            string `json:"output"`
+	ReplyTo           int    `json:"reply_to"`
+	ExplodingLifetime string `json:"exploding_lifetime"`
+	Alias             string `json:"alias"`
+	Query             string `json:"query"`
+	Username          string `json:"username"`
+	Role              string `json:"role"`
+
+	Name        string `json:"name"`
+	Public      bool   `json:"public"`
+	MembersType string `json:"members_type"`
+	TopicType   string `json:"topic_type"`
+	TopicName   string `json:"topic_name"`
+}
+
+// normalizeChannel fills in the defaults keybase applies to a channel
+func normalizeChannel(c keybase.Channel) keybase.Channel {
+	if c.MembersType == "" {
+		c.MembersType = keybase.USER
+	}
+	if c.TopicType == "" {
+		c.TopicType = keybase.CHAT
+	}
+	if c.MembersType == keybase.TEAM && c.TopicName == "" {
+		c.TopicName = "general"
+	}
+	if c.MembersType == keybase.USER {
+		names := strings.Split(c.Name, ",")
+		sort.Strings(names)
+		c.Name = strings.Join(names, ",")
+	}
+	return c
+}
+
+// channelKey identifies the conversation a channel refers to
+func channelKey(c keybase.Channel) string {
+	c = normalizeChannel(c)
+	return strings.Join([]string{c.Name, c.MembersType, c.TopicType, c.TopicName}, "|")
+}
+
+// conversation returns the conversation for the given channel, creating it
+// if necessary. New conversations are announced to listeners started with
+// --convs. s.mu must be held, and released with s.unlock.
+func (s *Server) conversation(c keybase.Channel) *conversation {
+	key := channelKey(c)
+	conv, ok := s.convs[key]
+	if !ok {
+		conv = &conversation{
+			id:       fmt.Sprintf("%064x", len(s.convs)+1),
+			channel:  normalizeChannel(c),
+			activeAt: time.Now(),
+		}
+		s.convs[key] = conv
+		s.convOrder = append(s.convOrder, conv)
+
+		out, _ := json.Marshal(map[string]interface{}{
+			"type": "chat_conv",
+			"conv": wireConversation(conv),
+		})
+		s.broadcast(func(l *listener) []byte {
+			if !l.convs {
+				return nil
+			}
+			return out
+		})
+	}
+	return conv
+}
+
+// addMessage stores a message in a conversation and delivers it to any
+// listeners. s.mu must be held, and released with s.unlock.
+func (s *Server) addMessage(conv *conversation, m *Message) {
+	m.ID = len(conv.messages) + 1
+	m.ConversationID = conv.id
+	m.Channel = conv.channel
+	if m.SentAt.IsZero() {
+		m.SentAt = time.Now()
+	}
+	conv.messages = append(conv.messages, m)
+	conv.activeAt = m.SentAt
+
+	local := m.Sender == s.username
+	s.broadcast(func(l *listener) []byte {
+		if !l.wants(m, local) {
+			return nil
+		}
+		source := "remote"
+		if local {
+			source = "local"
+		}
+		out, _ := json.Marshal(map[string]interface{}{
+			"type":   "chat",
+			"source": source,
+			"msg":    s.wireMessage(m),
+		})
+		return out
+	})
+}
+
+// message returns the message with the given ID in a conversation
+func (conv *conversation) message(id int) *Message {
+	if id < 1 || id > len(conv.messages) {
+		return nil
+	}
+	return conv.messages[id-1]
+}
+
+// InjectMessage adds a text message from sender to the given channel, and
+// delivers it to any running `chat api-listen` processes. It returns the ID
+// of the new message.
+func (s *Server) InjectMessage(channel keybase.Channel, sender, body string) int {
+	s.mu.Lock()
+	defer s.unlock()
+	m := &Message{Sender: sender, Type: "text", Body: body}
+	s.addMessage(s.conversation(channel), m)
+	return m.ID
+}
+
+// Messages returns a copy of all messages in the given channel, oldest first
+func (s *Server) Messages(channel keybase.Channel) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.convs[channelKey(channel)]
+	if !ok {
+		return nil
+	}
+	msgs := make([]Message, len(conv.messages))
+	for i, m := range conv.messages {
+		msgs[i] = *m
+	}
+	return msgs
+}
+
+// handleChat answers a request to the chat API
+func (s *Server) handleChat(method string, raw json.RawMessage) (interface{}, *apiError) {
+	var opts chatOptions
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, badOptions(err)
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	switch method {
+	case "list":
+		return s.chatList(opts), nil
+	case "searchinbox":
+		return map[string]interface{}{"hits": []interface{}{}}, nil
+	case "advertisecommands", "clearcommands":
+		return map[string]interface{}{"message": "ok"}, nil
+	}
+
+	if opts.Channel == nil || opts.Channel.Name == "" {
+		return nil, &apiError{Message: "channel name required"}
+	}
+	conv := s.conversation(*opts.Channel)
+
+	switch method {
+	case "send":
+		if opts.Message == nil {
+			return nil, &apiError{Message: "message body required"}
+		}
+		m := &Message{Sender: s.username, Type: "text", Body: opts.Message.Body, ReplyTo: opts.ReplyTo}
+		if d, err := time.ParseDuration(opts.ExplodingLifetime); err == nil && d > 0 {
+			m.Exploding = d
+		}
+		s.addMessage(conv, m)
+		return sent(m.ID), nil
+
+	case "edit", "reaction", "delete":
+		target := conv.message(opts.MessageID)
+		if target == nil || target.Deleted {
+			return nil, &apiError{Message: "message not found"}
+		}
+		m := &Message{Sender: s.username, Type: method, Target: target.ID}
+		switch method {
+		case "edit":
+			if opts.Message == nil {
+				return nil, &apiError{Message: "message body required"}
+			}
+			m.Body = opts.Message.Body
+			target.Body = m.Body
+		case "reaction":
+			if opts.Message == nil {
+				return nil, &apiError{Message: "reaction required"}
+			}
+			m.Body = opts.Message.Body
+		case "delete":
+			target.Deleted = true
+		}
+		s.addMessage(conv, m)
+		return sent(m.ID), nil
+
+	case "attach":
+		m := &Message{Sender: s.username, Type: "attachment", Body: opts.Title}
+		s.addMessage(conv, m)
+		return sent(m.ID), nil
+
+	case "read":
+		return s.chatRead(conv, opts), nil
+
+	case "download", "mark", "unpin", "addemoji", "addbotmember", "removebotmember":
+		if method == "unpin" {
+			conv.pinned = 0
+		}
+		return map[string]interface{}{"message": "ok"}, nil
+
+	case "pin":
+		if conv.message(opts.MessageID) == nil {
+			return nil, &apiError{Message: "message not found"}
+		}
+		conv.pinned = opts.MessageID
+		return map[string]interface{}{"message": "ok"}, nil
+	}
+	return nil, unknownMethod(method)
+}
+
+// sent returns the result of a request that sent a message
+func sent(id int) interface{} {
+	return map[string]interface{}{
+		"message":   "message sent",
+		"id":        id,
+		"outbox_id": fmt.Sprintf("%032x", id),
+	}
+}
+
+// chatList answers the chat API's list method. s.mu must be held.
+func (s *Server) chatList(opts chatOptions) interface{} {
+	convs := []interface{}{}
+	for _, conv := range s.convOrder {
+		c := conv.channel
+		if opts.Name != "" && normalizeChannel(keybase.Channel{Name: opts.Name, MembersType: c.MembersType}).Name != c.Name {
+			continue
+		}
+		if opts.MembersType != "" && opts.MembersType != c.MembersType ||
+			opts.TopicType != "" && opts.TopicType != c.TopicType ||
+			opts.TopicName != "" && opts.TopicName != c.TopicName {
+			continue
+		}
+		convs = append(convs, wireConversation(conv))
+	}
+	return map[string]interface{}{"conversations": convs}
+}
+
+// wireConversation returns the chat API's description of a conversation
+func wireConversation(conv *conversation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            conv.id,
+		"channel":       conv.channel,
+		"unread":        false,
+		"active_at":     conv.activeAt.Unix(),
+		"active_at_ms":  conv.activeAt.UnixNano() / int64(time.Millisecond),
+		"member_status": "active",
+	}
+}
+
+// chatRead answers the chat API's read method. Messages are returned newest
+// first. s.mu must be held.
+func (s *Server) chatRead(conv *conversation, opts chatOptions) interface{} {
+	num := 100
+	if opts.Pagination != nil && opts.Pagination.Num > 0 {
+		num = opts.Pagination.Num
+	}
+	msgs := []interface{}{}
+	for i := len(conv.messages) - 1; i >= 0 && len(msgs) < num; i-- {
+		if m := conv.messages[i]; !m.Deleted {
+			msgs = append(msgs, map[string]interface{}{"msg": s.wireMessage(m)})
+		}
+	}
+	return map[string]interface{}{
+		"messages": msgs,
+		"pagination": map[string]interface{}{
+			"num":  len(msgs),
+			"last": len(msgs) < num,
+		},
+	}
+}
+
+// wireMessage returns m in the format used by the chat API
+func (s *Server) wireMessage(m *Message) interface{} {
+	content := map[string]interface{}{"type": m.Type}
+	switch m.Type {
+	case "text":
+		content["text"] = map[string]interface{}{"body": m.Body, "replyTo": m.ReplyTo}
+	case "edit":
+		content["edit"] = map[string]interface{}{"messageID": m.Target, "body": m.Body}
+	case "reaction":
+		content["reaction"] = map[string]interface{}{"m": m.Target, "b": m.Body}
+	case "delete":
+		content["delete"] = map[string]interface{}{"messageIDs": []int{m.Target}}
+	case "attachment":
+		content["attachment"] = map[string]interface{}{
+			"object":   map[string]interface{}{"title": m.Body},
+			"uploaded": true,
+		}
+	}
+
+	out := map[string]interface{}{
+		"id":              m.ID,
+		"conversation_id": m.ConversationID,
+		"channel":         m.Channel,
+		"sender": map[string]interface{}{
+			"uid":         uid(m.Sender),
+			"username":    m.Sender,
+			"device_id":   uid(m.Sender + "/device"),
+			"device_name": "device",
+		},
+		"sent_at":    m.SentAt.Unix(),
+		"sent_at_ms": m.SentAt.UnixNano() / int64(time.Millisecond),
+		"content":    content,
+		"unread":     m.Sender != s.username,
+	}
+	if m.Exploding > 0 {
+		out["is_ephemeral"] = true
+		out["etime"] = m.SentAt.Add(m.Exploding).UnixNano() / int64(time.Millisecond)
+	}
+	return out
+}
+
+// listener is a running `chat api-listen` process
+type listener struct {
+	proc          *process
+	local         bool
+	hideExploding bool
+	wallet        bool
+	convs         bool
+	filters       []keybase.Channel
+	lines         chan []byte
+}
+
+// wants reports whether l should receive m
+func (l *listener) wants(m *Message, local bool) bool {
+	if local && !l.local {
+		return false
+	}
+	if l.hideExploding && m.Exploding > 0 {
+		return false
+	}
+	if len(l.filters) == 0 {
+		return true
+	}
+	for _, f := range l.filters {
+		if channelKey(f) == channelKey(m.Channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// listen starts a fake `chat api-listen` process with the given flags
+func (s *Server) listen(ctx context.Context, args []string) (*process, error) {
+	l := &listener{
+		proc:  newProcess(ctx),
+		lines: make(chan []byte, 1024),
+	}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--local":
+			l.local = true
+		case "--hide-exploding":
+			l.hideExploding = true
+		case "--wallet":
+			l.wallet = true
+		case "--convs":
+			l.convs = true
+		case "--dev":
+		case "--filter-channel", "--filter-channels":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("keybasetest: %s requires a value", args[i])
+			}
+			i++
+			if args[i-1] == "--filter-channel" {
+				var c keybase.Channel
+				if err := json.Unmarshal([]byte(args[i]), &c); err != nil {
+					return nil, err
+				}
+				l.filters = append(l.filters, c)
+			} else {
+				var cs []keybase.Channel
+				if err := json.Unmarshal([]byte(args[i]), &cs); err != nil {
+					return nil, err
+				}
+				l.filters = append(l.filters, cs...)
+			}
+		default:
+			return nil, fmt.Errorf("keybasetest: unsupported api-listen flag %q", args[i])
+		}
+	}
+
+	s.mu.Lock()
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.listeners, l)
+			s.mu.Unlock()
+		}()
+		for {
+			select {
+			case line := <-l.lines:
+				if _, err := l.proc.stdoutW.Write(append(line, '\n')); err != nil {
+					return
+				}
+			case <-l.proc.done:
+				return
+			}
+		}
+	}()
+	return l.proc, nil
+}
+
+// delivery is a line queued up by broadcast for a listener
+type delivery struct {
+	l    *listener
+	line []byte
+}
+
+// broadcast queues a line for every listener. render is called for each
+// listener, and returns the line to send it, or nil to skip it. s.mu must be
+// held, and released with s.unlock so that the lines are sent.
+func (s *Server) broadcast(render func(*listener) []byte) {
+	for l := range s.listeners {
+		if line := render(l); line != nil {
+			s.outbox = append(s.outbox, delivery{l: l, line: line})
+		}
+	}
+}
+
+// unlock releases s.mu, then sends the lines queued by broadcast. They are
+// sent without holding s.mu, so that a listener whose buffer is full doesn't
+// block the requests that its handlers make to s.
+func (s *Server) unlock() {
+	outbox := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+	for _, d := range outbox {
+		select {
+		case d.l.lines <- d.line:
+		case <-d.l.proc.done:
+		}
+	}
+}
+
+// InjectListenError sends an error to every running `chat api-listen`
+// process, as keybase does when the listener fails
+func (s *Server) InjectListenError(message string) {
+	s.mu.Lock()
+	defer s.unlock()
+	out, _ := json.Marshal(map[string]interface{}{"error": message})
+	s.broadcast(func(*listener) []byte { return out })
+}
+
+// Listeners returns the number of running `chat api-listen` processes
+func (s *Server) Listeners() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listeners)
+}
+
+// KillListeners makes every running `chat api-listen` process exit with the
+// given error, as if it had crashed. The error's message is written to the
+// processes' stderr.
+func (s *Server) KillListeners(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		if err != nil {
+			l.proc.mu.Lock()
+			l.proc.stderr = err.Error() + "\n"
+			l.proc.mu.Unlock()
+		}
+		l.proc.exit(err)
+		delete(s.listeners, l)
+	}
+}
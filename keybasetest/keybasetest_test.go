@@ -0,0 +1,230 @@
+package keybasetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestChat(t *testing.T) {
+	for _, sessions := range []bool{false, true} {
+		srv := keybasetest.NewServer("bot")
+		var opts []keybase.Option
+		if !sessions {
+			opts = append(opts, keybase.WithoutSessions())
+		}
+		k := srv.Keybase(t, opts...)
+		defer k.Close()
+
+		if k.Username != "bot" || !k.LoggedIn {
+			t.Fatalf("sessions=%v: got user %q, logged in %v", sessions, k.Username, k.LoggedIn)
+		}
+
+		chat := k.NewChat(keybase.Channel{Name: "alice,bot"})
+		if _, err := chat.Send("hello", "alice"); err != nil {
+			t.Fatalf("sessions=%v: Send: %v", sessions, err)
+		}
+		srv.InjectMessage(keybase.Channel{Name: "bot,alice"}, "alice", "hi bot")
+
+		msgs := srv.Messages(keybase.Channel{Name: "alice,bot"})
+		if len(msgs) != 2 || msgs[0].Body != "hello alice" || msgs[1].Sender != "alice" {
+			t.Fatalf("sessions=%v: got messages %+v", sessions, msgs)
+		}
+
+		r, err := chat.Read(1)
+		if err != nil {
+			t.Fatalf("sessions=%v: Read: %v", sessions, err)
+		}
+		if n := len(r.Result.Messages); n != 1 {
+			t.Fatalf("sessions=%v: Read returned %d messages", sessions, n)
+		}
+		if body := r.Result.Messages[0].Msg.Content.Text.Body; body != "hi bot" {
+			t.Errorf("sessions=%v: got body %q", sessions, body)
+		}
+	}
+}
+
+func TestInjectError(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	srv.InjectError("chat", "send", 2504, "not in conversation")
+	chat := k.NewChat(keybase.Channel{Name: "bot"})
+	if _, err := chat.Send("hello"); !errors.Is(err, keybase.ErrPermissionDenied) {
+		t.Fatalf("got %v, want ErrPermissionDenied", err)
+	}
+	if _, err := chat.Send("hello"); err != nil {
+		t.Fatalf("second Send: %v", err)
+	}
+}
+
+func TestTeam(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	if _, err := k.NewTeam("nope").MemberList(); !errors.Is(err, keybase.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	srv.AddTeam("acme", map[string]string{"alice": "reader"})
+	team := k.NewTeam("acme")
+	if _, err := team.AddWriters("carol"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := team.MemberList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.Result.Members.Writers); n != 1 {
+		t.Errorf("got %d writers, want 1", n)
+	}
+	if role := srv.TeamMembers("acme")["carol"]; role != "writer" {
+		t.Errorf("carol has role %q, want writer", role)
+	}
+}
+
+func TestKV(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	kv := srv.Keybase(t).NewKV("")
+
+	r, err := kv.Put("ns", "key", "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Result.Revision != 1 {
+		t.Fatalf("got revision %d, want 1", r.Result.Revision)
+	}
+	if _, err := kv.Put("ns", "key", "two", 1); !errors.Is(err, keybase.ErrRevisionConflict) {
+		t.Fatalf("got %v, want ErrRevisionConflict", err)
+	}
+	if _, err := kv.Put("ns", "key", "two", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = kv.Get("ns", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Result.EntryValue != "two" || r.Result.Revision != 2 {
+		t.Errorf("got %q at revision %d", r.Result.EntryValue, r.Result.Revision)
+	}
+
+	if _, err := kv.Delete("ns", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Delete("ns", "key"); !errors.Is(err, keybase.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if _, _, ok := srv.KVEntry("bot", "ns", "key"); ok {
+		t.Error("entry still exists after Delete")
+	}
+}
+
+func TestWallet(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	w := srv.Keybase(t).NewWallet()
+
+	if _, err := w.SendXLM("alice", "5"); err == nil {
+		t.Fatal("sent XLM without a balance")
+	}
+	srv.SetBalance("bot", 10)
+	r, err := w.SendXLM("alice", "4", "thanks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.Balance("alice"); got != 4 {
+		t.Errorf("alice has %v XLM, want 4", got)
+	}
+
+	tx, err := w.TxDetail(r.Result.TxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Result.ToUsername != "alice" || tx.Result.Note != "thanks" {
+		t.Errorf("got %+v", tx.Result)
+	}
+	addr, err := w.StellarAddress("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := w.StellarUser(addr); err != nil || user != "alice" {
+		t.Errorf("got %q, %v", user, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	received := make(chan keybase.ChatAPI, 1)
+	done := make(chan struct{})
+	go func() {
+		k.Run(func(m keybase.ChatAPI) { received <- m })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Listeners() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("api-listen wasn't started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	srv.InjectMessage(keybase.Channel{Name: "alice,bot"}, "alice", "ping")
+
+	select {
+	case m := <-received:
+		if m.Msg == nil || m.Msg.Content.Text.Body != "ping" || m.Msg.Sender.Username != "alice" {
+			t.Errorf("got %+v", m.Msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message wasn't delivered")
+	}
+
+	k.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after Close")
+	}
+}
+
+func TestSlowListener(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+	channel := keybase.Channel{Name: "alice,bot"}
+
+	// A listener that isn't read from fills up its buffer, and then blocks
+	// whoever delivers messages to it, but not the rest of the Server
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := srv.Start(ctx, "chat", "api-listen"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < 2000 && ctx.Err() == nil; i++ {
+			srv.InjectMessage(channel, "alice", "ping")
+		}
+	}()
+
+	done := make(chan error)
+	go func() {
+		for len(srv.Messages(channel)) <= 1024 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		_, err := k.NewChat(channel).Read(1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server is blocked by a slow listener")
+	}
+}
@@ -0,0 +1,159 @@
+package keybasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// kvKey identifies an entry in the KV store
+type kvKey struct {
+	team      string
+	namespace string
+	key       string
+}
+
+// kvEntry is an entry in the KV store. Deleted entries keep their revision,
+// so that it keeps increasing if the entry is written again.
+type kvEntry struct {
+	value    string
+	revision uint
+	deleted  bool
+}
+
+// kvOptions holds the options of a kvstore API request
+type kvOptions struct {
+	Team       string `json:"team"`
+	Namespace  string `json:"namespace"`
+	EntryKey   string `json:"entryKey"`
+	Revision   uint   `json:"revision"`
+	EntryValue string `json:"entryValue"`
+}
+
+// KVEntry returns the value and revision of an entry in the KV store. ok is
+// false if the entry doesn't exist, or was deleted.
+func (s *Server) KVEntry(team, namespace, key string) (value string, revision uint, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.kv[kvKey{team, namespace, key}]
+	if !found || e.deleted {
+		return "", 0, false
+	}
+	return e.value, e.revision, true
+}
+
+// handleKV answers a request to the kvstore API
+func (s *Server) handleKV(method string, raw json.RawMessage) (interface{}, *apiError) {
+	var opts kvOptions
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, badOptions(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if opts.Team == "" {
+		opts.Team = s.username
+	}
+	if method != "list" && (opts.Namespace == "" || opts.EntryKey == "") {
+		return nil, &apiError{Message: "namespace and entryKey are required"}
+	}
+	key := kvKey{opts.Team, opts.Namespace, opts.EntryKey}
+	e := s.kv[key]
+
+	switch method {
+	case "list":
+		if opts.Namespace == "" {
+			return map[string]interface{}{"teamName": opts.Team, "namespaces": s.kvNamespaces(opts.Team)}, nil
+		}
+		return map[string]interface{}{
+			"teamName":  opts.Team,
+			"namespace": opts.Namespace,
+			"entryKeys": s.kvKeys(opts.Team, opts.Namespace),
+		}, nil
+
+	case "get":
+		result := map[string]interface{}{
+			"teamName":   opts.Team,
+			"namespace":  opts.Namespace,
+			"entryKey":   opts.EntryKey,
+			"entryValue": nil,
+			"revision":   0,
+		}
+		if e != nil {
+			result["revision"] = e.revision
+			if !e.deleted {
+				result["entryValue"] = e.value
+			}
+		}
+		return result, nil
+
+	case "put", "del":
+		if e == nil {
+			if method == "del" {
+				return nil, kvNotFound()
+			}
+			e = &kvEntry{deleted: true}
+		}
+		if method == "del" && e.deleted {
+			return nil, kvNotFound()
+		}
+		if opts.Revision != 0 && opts.Revision != e.revision+1 {
+			return nil, &apiError{Code: 2760, Message: fmt.Sprintf("wrong revision: expected revision %d", e.revision+1)}
+		}
+		e.revision++
+		e.deleted = method == "del"
+		if method == "put" {
+			e.value = opts.EntryValue
+		}
+		s.kv[key] = e
+		return map[string]interface{}{
+			"teamName":  opts.Team,
+			"namespace": opts.Namespace,
+			"entryKey":  opts.EntryKey,
+			"revision":  e.revision,
+		}, nil
+	}
+	return nil, unknownMethod(method)
+}
+
+// kvNotFound returns the error the kvstore API returns for missing entries
+func kvNotFound() *apiError {
+	return &apiError{Code: 2762, Message: "entry not found"}
+}
+
+// kvNamespaces returns the namespaces of a team that have any entries that
+// haven't been deleted. s.mu must be held.
+func (s *Server) kvNamespaces(team string) []string {
+	seen := map[string]bool{}
+	namespaces := []string{}
+	for k, e := range s.kv {
+		if k.team == team && !e.deleted && !seen[k.namespace] {
+			seen[k.namespace] = true
+			namespaces = append(namespaces, k.namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// kvKeys returns the keys in a namespace that haven't been deleted. s.mu
+// must be held.
+func (s *Server) kvKeys(team, namespace string) []interface{} {
+	var names []string
+	for k, e := range s.kv {
+		if k.team == team && k.namespace == namespace && !e.deleted {
+			names = append(names, k.key)
+		}
+	}
+	sort.Strings(names)
+
+	keys := []interface{}{}
+	for _, name := range names {
+		keys = append(keys, map[string]interface{}{
+			"entryKey": name,
+			"revision": s.kv[kvKey{team, namespace, name}].revision,
+		})
+	}
+	return keys
+}
@@ -0,0 +1,70 @@
+package keybasetest
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// rateLimit is a rate-limit tank enforced on the chat API
+type rateLimit struct {
+	tank     string
+	capacity int
+	gas      int
+	period   time.Duration
+	reset    time.Time
+}
+
+// SetRateLimit makes the chat API enforce a rate limit, as keybase does. Each
+// request uses one unit of gas from the tank, which holds capacity units and
+// is refilled every period. Requests are rejected while the tank is empty.
+// Every response reports the state of the tank.
+func (s *Server) SetRateLimit(tank string, capacity int, period time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimits = append(s.rateLimits, &rateLimit{
+		tank:     tank,
+		capacity: capacity,
+		gas:      capacity,
+		period:   period,
+		reset:    time.Now().Add(period),
+	})
+}
+
+// takeGas takes one unit of gas from every tank, and returns whether any of
+// them was empty, along with the state of the tanks as reported by the chat
+// API. s.mu must be held.
+func (s *Server) takeGas() (limited bool, limits []interface{}) {
+	now := time.Now()
+	for _, rl := range s.rateLimits {
+		for !rl.reset.After(now) {
+			rl.gas = rl.capacity
+			rl.reset = rl.reset.Add(rl.period)
+		}
+		if rl.gas <= 0 {
+			limited = true
+		}
+	}
+	for _, rl := range s.rateLimits {
+		if !limited {
+			rl.gas--
+		}
+		reset := rl.reset.Sub(now)
+		limits = append(limits, map[string]interface{}{
+			"tank":     rl.tank,
+			"capacity": rl.capacity,
+			"gas":      rl.gas,
+			"reset":    int((reset + time.Second - 1) / time.Second),
+		})
+	}
+	return limited, limits
+}
+
+// rateLimited returns the chat API's response to a request rejected by the
+// rate limiter
+func rateLimited(limits []interface{}) []byte {
+	out, _ := json.Marshal(map[string]interface{}{
+		"error":      apiError{Code: 2501, Message: "rate limit exceeded"},
+		"ratelimits": limits,
+	})
+	return out
+}
@@ -0,0 +1,394 @@
+/*
+Package keybasetest provides an in-memory fake of the keybase service for
+testing code built on the keybase package.
+
+A Server implements keybase.Executor. It speaks the `chat api`, `chat
+api-listen`, `team api`, `kvstore api` and `wallet api` JSON protocols, both
+in one-shot mode (-m) and in session mode, against in-memory conversations,
+teams, KV entries and wallet balances:
+
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	chat := k.NewChat(keybase.Channel{Name: "bot,alice"})
+	chat.Send("hello")
+
+	msgs := srv.Messages(chat.Channel) // msgs[0].Body == "hello"
+
+Incoming messages and failures can be injected with InjectMessage and
+InjectError.
+*/
+package keybasetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"samhofi.us/x/keybase"
+)
+
+// DefaultVersion is the client version reported by a new Server
+const DefaultVersion = "5.5.2-20200710162215+e3cd3d5d70"
+
+// Server is an in-memory stand-in for the keybase client and service. It is
+// safe for concurrent use.
+type Server struct {
+	mu sync.Mutex
+
+	username string
+	device   string
+	loggedIn bool
+	version  string
+
+	convs     map[string]*conversation // keyed by channelKey
+	convOrder []*conversation
+	teams     map[string]*team
+	kv        map[kvKey]*kvEntry
+	wallets   map[string]*wallet // keyed by username
+	txs       map[string]walletTx
+	nextID    int
+
+	errors     map[string][]apiError // keyed by "api method"
+	rateLimits []*rateLimit
+	listeners  map[*listener]struct{}
+	outbox     []delivery // Lines queued by broadcast, sent by unlock
+}
+
+// apiError is an error queued up by InjectError
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewServer returns a Server with the given user logged in
+func NewServer(username string) *Server {
+	return &Server{
+		username:  username,
+		device:    "keybasetest",
+		loggedIn:  true,
+		version:   DefaultVersion,
+		convs:     make(map[string]*conversation),
+		teams:     make(map[string]*team),
+		kv:        make(map[kvKey]*kvEntry),
+		wallets:   make(map[string]*wallet),
+		txs:       make(map[string]walletTx),
+		errors:    make(map[string][]apiError),
+		listeners: make(map[*listener]struct{}),
+	}
+}
+
+// Keybase returns a *keybase.Keybase that runs all of its commands against s.
+// If it can't be created, t fails.
+func (s *Server) Keybase(t testing.TB, opts ...keybase.Option) *keybase.Keybase {
+	t.Helper()
+	opts = append([]keybase.Option{keybase.WithExecutor(s)}, opts...)
+	k, err := keybase.New(opts...)
+	if err != nil {
+		t.Fatal("keybasetest: " + err.Error())
+	}
+	return k
+}
+
+// SetVersion sets the client version reported by `keybase version`
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// InjectError makes the next request for the given method of the given API
+// ("chat", "team", "kvstore" or "wallet") fail with the given status code and
+// message. Errors for the same method are returned in the order they were
+// injected.
+func (s *Server) InjectError(api, method string, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := api + " " + method
+	s.errors[key] = append(s.errors[key], apiError{Code: code, Message: message})
+}
+
+// takeError returns the next injected error for the given API method, if
+// there is one. s.mu must be held.
+func (s *Server) takeError(api, method string) *apiError {
+	key := api + " " + method
+	errs := s.errors[key]
+	if len(errs) == 0 {
+		return nil
+	}
+	s.errors[key] = errs[1:]
+	return &errs[0]
+}
+
+// Output implements keybase.Executor
+func (s *Server) Output(ctx context.Context, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	args = stripGlobalFlags(args)
+	cmd := strings.Join(args, " ")
+
+	switch {
+	case cmd == "status -j":
+		return s.status(), nil
+	case cmd == "version -S -f s":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return []byte(s.version + "\n"), nil
+	case len(args) == 4 && args[1] == "api" && args[2] == "-m":
+		return s.handle(args[0], []byte(args[3]))
+	case len(args) > 0 && args[0] == "oneshot":
+		return nil, s.oneshot(keybase.CommandEnvFrom(ctx))
+	case len(args) > 0 && args[0] == "logout":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.loggedIn = false
+		return nil, nil
+	case cmd == "ctl stop":
+		return nil, nil
+	case len(args) > 1 && args[0] == "wallet" && (args[1] == "request" || args[1] == "cancel-request"):
+		return nil, nil
+	}
+	return nil, fmt.Errorf("keybasetest: unsupported command %q", cmd)
+}
+
+// Start implements keybase.Executor
+func (s *Server) Start(ctx context.Context, args ...string) (keybase.Process, error) {
+	args = stripGlobalFlags(args)
+	switch {
+	case len(args) >= 2 && args[0] == "chat" && args[1] == "api-listen":
+		p, err := s.listen(ctx, args[2:])
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case len(args) == 2 && args[1] == "api":
+		p := newProcess(ctx)
+		go s.serveSession(p, args[0])
+		return p, nil
+	case len(args) == 1 && args[0] == "service":
+		return newProcess(ctx), nil
+	}
+	return nil, fmt.Errorf("keybasetest: unsupported command %q", strings.Join(args, " "))
+}
+
+// serveSession answers newline-delimited JSON requests written to p's stdin,
+// like `keybase <api> api` does when it's run without -m
+func (s *Server) serveSession(p *process, api string) {
+	dec := json.NewDecoder(p.stdinR)
+	for {
+		var req json.RawMessage
+		if err := dec.Decode(&req); err != nil {
+			p.exit(nil)
+			return
+		}
+		out, err := s.handle(api, req)
+		if err != nil {
+			p.exit(err)
+			return
+		}
+		if _, err := p.stdoutW.Write(append(out, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// request is a request to one of the JSON APIs
+type request struct {
+	Method string `json:"method"`
+	Params struct {
+		Options json.RawMessage `json:"options"`
+	} `json:"params"`
+}
+
+// handle answers a single request to one of the JSON APIs
+func (s *Server) handle(api string, raw []byte) ([]byte, error) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return respond(nil, &apiError{Message: "invalid JSON: " + err.Error()}), nil
+	}
+	if len(req.Params.Options) == 0 {
+		req.Params.Options = json.RawMessage("{}")
+	}
+
+	s.mu.Lock()
+	if !s.loggedIn {
+		s.mu.Unlock()
+		return respond(nil, &apiError{Code: 201, Message: "login required"}), nil
+	}
+	if err := s.takeError(api, req.Method); err != nil {
+		s.mu.Unlock()
+		return respond(nil, err), nil
+	}
+	var limits []interface{}
+	if api == "chat" && len(s.rateLimits) > 0 {
+		var limited bool
+		if limited, limits = s.takeGas(); limited {
+			s.mu.Unlock()
+			return rateLimited(limits), nil
+		}
+	}
+	s.mu.Unlock()
+
+	var (
+		result interface{}
+		err    *apiError
+	)
+	switch api {
+	case "chat":
+		result, err = s.handleChat(req.Method, req.Params.Options)
+	case "team":
+		result, err = s.handleTeam(req.Method, req.Params.Options)
+	case "kvstore":
+		result, err = s.handleKV(req.Method, req.Params.Options)
+	case "wallet":
+		result, err = s.handleWallet(req.Method, req.Params.Options)
+	default:
+		return nil, fmt.Errorf("keybasetest: unsupported api %q", api)
+	}
+	if m, ok := result.(map[string]interface{}); ok && limits != nil {
+		m["ratelimits"] = limits
+	}
+	return respond(result, err), nil
+}
+
+// respond encodes a response to one of the JSON APIs
+func respond(result interface{}, err *apiError) []byte {
+	var out []byte
+	if err != nil {
+		out, _ = json.Marshal(map[string]interface{}{"error": err})
+	} else {
+		out, _ = json.Marshal(map[string]interface{}{"result": result})
+	}
+	return out
+}
+
+// unknownMethod returns the error the APIs return for methods they don't know
+func unknownMethod(method string) *apiError {
+	return &apiError{Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+// badOptions returns the error the APIs return for malformed options
+func badOptions(err error) *apiError {
+	return &apiError{Message: "invalid options: " + err.Error()}
+}
+
+// status returns the output of `keybase status -j`
+func (s *Server) status() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := map[string]interface{}{
+		"LoggedIn": s.loggedIn,
+		"Service":  map[string]interface{}{"Running": true, "Version": s.version},
+		"Client":   map[string]interface{}{"Version": s.version},
+	}
+	if s.loggedIn {
+		st["Username"] = s.username
+		st["UserID"] = uid(s.username)
+		st["Device"] = map[string]interface{}{"name": s.device, "deviceID": uid(s.device)}
+	}
+	out, _ := json.Marshal(st)
+	return out
+}
+
+// oneshot handles `keybase oneshot`, which reads the username and paper key
+// from the KEYBASE_USERNAME and KEYBASE_PAPERKEY environment variables
+func (s *Server) oneshot(env []string) error {
+	var username, paperkey string
+	for _, v := range env {
+		switch {
+		case strings.HasPrefix(v, "KEYBASE_USERNAME="):
+			username = strings.TrimPrefix(v, "KEYBASE_USERNAME=")
+		case strings.HasPrefix(v, "KEYBASE_PAPERKEY="):
+			paperkey = strings.TrimPrefix(v, "KEYBASE_PAPERKEY=")
+		}
+	}
+	if username == "" || paperkey == "" {
+		return errors.New("keybasetest: oneshot requires KEYBASE_USERNAME and KEYBASE_PAPERKEY")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username = username
+	s.device = "oneshot"
+	s.loggedIn = true
+	return nil
+}
+
+// stripGlobalFlags removes the flags that CommandExecutor passes to every
+// command, so that a Server can also be used behind other Executors
+func stripGlobalFlags(args []string) []string {
+	for len(args) >= 2 && (args[0] == "--home" || args[0] == "--socket-file") {
+		args = args[2:]
+	}
+	return args
+}
+
+// uid returns a stable, fake keybase ID for the given name
+func uid(name string) string {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%016x%016x", h.Sum64(), h.Sum64()^0x19)
+}
+
+// process is a keybase.Process run by a Server
+type process struct {
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+
+	mu     sync.Mutex
+	stderr string
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// newProcess returns a process that is killed when ctx is done
+func newProcess(ctx context.Context) *process {
+	p := &process{done: make(chan struct{})}
+	p.stdinR, p.stdinW = io.Pipe()
+	p.stdoutR, p.stdoutW = io.Pipe()
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.exit(ctx.Err())
+		case <-p.done:
+		}
+	}()
+	return p
+}
+
+// exit ends the process with the given error
+func (p *process) exit(err error) {
+	p.once.Do(func() {
+		p.err = err
+		p.stdoutW.Close()
+		p.stdinR.Close()
+		close(p.done)
+	})
+}
+
+func (p *process) Stdin() io.WriteCloser { return p.stdinW }
+func (p *process) Stdout() io.Reader     { return p.stdoutR }
+
+// Stderr returns what the process wrote to stderr. The keybase package uses
+// it to report why `chat api-listen` exited.
+func (p *process) Stderr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stderr
+}
+
+func (p *process) Wait() error {
+	<-p.done
+	return p.err
+}
@@ -0,0 +1,172 @@
+package keybasetest
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Team roles, in the order they're listed by the team API
+var roles = []string{"owner", "admin", "writer", "reader"}
+
+// Numeric role values used by list-user-memberships
+var roleValues = map[string]int{"reader": 1, "writer": 2, "admin": 3, "owner": 4}
+
+// team holds the members of a team, keyed by username
+type team struct {
+	name    string
+	members map[string]string
+}
+
+// teamOptions holds the options of a team API request
+type teamOptions struct {
+	Team      string `json:"team"`
+	Username  string `json:"username"`
+	Usernames []struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	} `json:"usernames"`
+}
+
+// AddTeam creates a team with the given members, keyed by username, whose
+// values are their roles. The Server's user is added as an owner.
+func (s *Server) AddTeam(name string, members map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &team{name: name, members: map[string]string{s.username: "owner"}}
+	for user, role := range members {
+		t.members[user] = role
+	}
+	s.teams[name] = t
+}
+
+// TeamMembers returns the members of a team, keyed by username, whose values
+// are their roles. It returns nil if the team doesn't exist.
+func (s *Server) TeamMembers(name string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.teams[name]
+	if !ok {
+		return nil
+	}
+	members := make(map[string]string, len(t.members))
+	for user, role := range t.members {
+		members[user] = role
+	}
+	return members
+}
+
+// teamNotFound returns the error the team API returns for unknown teams
+func teamNotFound(name string) *apiError {
+	return &apiError{Code: 2614, Message: "team not found: " + name}
+}
+
+// handleTeam answers a request to the team API
+func (s *Server) handleTeam(method string, raw json.RawMessage) (interface{}, *apiError) {
+	var opts teamOptions
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, badOptions(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch method {
+	case "create-team":
+		if _, ok := s.teams[opts.Team]; ok {
+			return nil, &apiError{Code: 2619, Message: "team already exists: " + opts.Team}
+		}
+		if i := strings.LastIndex(opts.Team, "."); i >= 0 {
+			if _, ok := s.teams[opts.Team[:i]]; !ok {
+				return nil, teamNotFound(opts.Team[:i])
+			}
+		}
+		s.teams[opts.Team] = &team{name: opts.Team, members: map[string]string{s.username: "owner"}}
+		return map[string]interface{}{"chatSent": true, "creatorAdded": true}, nil
+
+	case "add-members":
+		t, ok := s.teams[opts.Team]
+		if !ok {
+			return nil, teamNotFound(opts.Team)
+		}
+		for _, u := range opts.Usernames {
+			if _, ok := roleValues[u.Role]; !ok {
+				return nil, &apiError{Message: "invalid role: " + u.Role}
+			}
+		}
+		for _, u := range opts.Usernames {
+			t.members[u.Username] = u.Role
+		}
+		return map[string]interface{}{"chatSent": true}, nil
+
+	case "remove-member":
+		t, ok := s.teams[opts.Team]
+		if !ok {
+			return nil, teamNotFound(opts.Team)
+		}
+		if _, ok := t.members[opts.Username]; !ok {
+			return nil, &apiError{Message: opts.Username + " is not a member of " + opts.Team}
+		}
+		delete(t.members, opts.Username)
+		return map[string]interface{}{}, nil
+
+	case "list-team-memberships":
+		t, ok := s.teams[opts.Team]
+		if !ok {
+			return nil, teamNotFound(opts.Team)
+		}
+		members := map[string][]interface{}{}
+		for _, role := range roles {
+			members[role+"s"] = []interface{}{}
+		}
+		for _, user := range t.usernames() {
+			role := t.members[user]
+			members[role+"s"] = append(members[role+"s"], map[string]interface{}{
+				"uv":       map[string]interface{}{"uid": uid(user), "eldestSeqno": 1},
+				"username": user,
+				"status":   0,
+			})
+		}
+		return map[string]interface{}{"members": members}, nil
+
+	case "list-user-memberships":
+		teams := []interface{}{}
+		for _, name := range s.teamNames() {
+			t := s.teams[name]
+			role, ok := t.members[opts.Username]
+			if !ok {
+				continue
+			}
+			teams = append(teams, map[string]interface{}{
+				"team_id":      uid(name),
+				"fq_name":      name,
+				"username":     opts.Username,
+				"uid":          uid(opts.Username),
+				"role":         roleValues[role],
+				"member_count": len(t.members),
+			})
+		}
+		return map[string]interface{}{"teams": teams}, nil
+	}
+	return nil, unknownMethod(method)
+}
+
+// usernames returns the usernames of t's members, in order
+func (t *team) usernames() []string {
+	names := make([]string, 0, len(t.members))
+	for name := range t.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// teamNames returns the names of all teams, in order. s.mu must be held.
+func (s *Server) teamNames() []string {
+	names := make([]string, 0, len(s.teams))
+	for name := range s.teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,200 @@
+package keybasetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wallet is a user's primary stellar account
+type wallet struct {
+	accountID string
+	balance   float64
+}
+
+// walletTx is a payment made with the wallet API
+type walletTx struct {
+	id       string
+	from     string
+	to       string
+	amount   float64
+	currency string
+	note     string
+	time     time.Time
+}
+
+// walletOptions holds the options of a wallet API request
+type walletOptions struct {
+	Name      string `json:"name"`
+	Txid      string `json:"txid"`
+	Recipient string `json:"recipient"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
+	Message   string `json:"message"`
+}
+
+// SetBalance sets the XLM balance of a user's wallet, creating the wallet if
+// it doesn't exist yet
+func (s *Server) SetBalance(username string, amount float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wallet(username).balance = amount
+}
+
+// Balance returns the XLM balance of a user's wallet
+func (s *Server) Balance(username string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wallet(username).balance
+}
+
+// wallet returns a user's wallet, creating it if it doesn't exist yet. s.mu
+// must be held.
+func (s *Server) wallet(username string) *wallet {
+	w, ok := s.wallets[username]
+	if !ok {
+		w = &wallet{accountID: "G" + strings.ToUpper(uid(username) + uid(username+"/stellar"))[:55]}
+		s.wallets[username] = w
+	}
+	return w
+}
+
+// handleWallet answers a request to the wallet API. Amounts in currencies
+// other than XLM are treated as if they were XLM.
+func (s *Server) handleWallet(method string, raw json.RawMessage) (interface{}, *apiError) {
+	var opts walletOptions
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, badOptions(err)
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	switch method {
+	case "lookup":
+		for username, w := range s.wallets {
+			if w.accountID == opts.Name {
+				return map[string]interface{}{"accountID": w.accountID, "username": username}, nil
+			}
+		}
+		if strings.HasPrefix(opts.Name, "G") && len(opts.Name) == 56 {
+			return nil, &apiError{Message: "account not found: " + opts.Name}
+		}
+		return map[string]interface{}{"accountID": s.wallet(opts.Name).accountID, "username": opts.Name}, nil
+
+	case "send":
+		amount, err := strconv.ParseFloat(opts.Amount, 64)
+		if err != nil || amount <= 0 {
+			return nil, &apiError{Message: "invalid amount: " + opts.Amount}
+		}
+		from := s.wallet(s.username)
+		if from.balance < amount {
+			return nil, &apiError{Message: "insufficient funds"}
+		}
+		from.balance -= amount
+		s.wallet(opts.Recipient).balance += amount
+
+		s.nextID++
+		tx := walletTx{
+			id:       fmt.Sprintf("%064x", s.nextID),
+			from:     s.username,
+			to:       opts.Recipient,
+			amount:   amount,
+			currency: opts.Currency,
+			note:     opts.Message,
+			time:     time.Now(),
+		}
+		s.txs[tx.id] = tx
+		s.notifyPayment(tx)
+		return s.txResult(tx), nil
+
+	case "details":
+		tx, ok := s.txs[opts.Txid]
+		if !ok {
+			return nil, &apiError{Message: "transaction not found: " + opts.Txid}
+		}
+		return s.txResult(tx), nil
+	}
+	return nil, unknownMethod(method)
+}
+
+// txResult returns the wallet API's description of a payment. s.mu must be
+// held.
+func (s *Server) txResult(tx walletTx) map[string]interface{} {
+	return map[string]interface{}{
+		"txID":            tx.id,
+		"time":            tx.time.UnixNano() / int64(time.Millisecond),
+		"status":          "completed",
+		"amount":          strconv.FormatFloat(tx.amount, 'f', 7, 64),
+		"asset":           map[string]interface{}{"type": "native"},
+		"displayAmount":   strconv.FormatFloat(tx.amount, 'f', 2, 64),
+		"displayCurrency": tx.currency,
+		"fromStellar":     s.wallet(tx.from).accountID,
+		"toStellar":       s.wallet(tx.to).accountID,
+		"fromUsername":    tx.from,
+		"toUsername":      tx.to,
+		"note":            tx.note,
+	}
+}
+
+// InjectPayment sends amount XLM from another user to the Server's user, and
+// delivers a wallet notification to any `chat api-listen --wallet` processes.
+// It returns the ID of the transaction.
+func (s *Server) InjectPayment(from string, amount float64, note string) string {
+	s.mu.Lock()
+	defer s.unlock()
+	s.wallet(s.username).balance += amount
+
+	s.nextID++
+	tx := walletTx{
+		id:       fmt.Sprintf("%064x", s.nextID),
+		from:     from,
+		to:       s.username,
+		amount:   amount,
+		currency: "XLM",
+		note:     note,
+		time:     time.Now(),
+	}
+	s.txs[tx.id] = tx
+	s.notifyPayment(tx)
+	return tx.id
+}
+
+// notifyPayment delivers a wallet notification about tx to the listeners
+// that subscribed to wallet events. s.mu must be held, and released with
+// s.unlock.
+func (s *Server) notifyPayment(tx walletTx) {
+	delta := 1 // Incoming
+	if tx.from == s.username {
+		delta = 2
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"type":   "wallet",
+		"source": "remote",
+		"notification": map[string]interface{}{
+			"summary": map[string]interface{}{
+				"id":                tx.id,
+				"txID":              tx.id,
+				"time":              tx.time.UnixNano() / int64(time.Millisecond),
+				"statusSimplified":  3, // Completed
+				"statusDescription": "completed",
+				"amountDescription": strconv.FormatFloat(tx.amount, 'f', -1, 64) + " XLM",
+				"delta":             delta,
+				"fromAccountID":     s.wallet(tx.from).accountID,
+				"fromUsername":      tx.from,
+				"toAccountID":       s.wallet(tx.to).accountID,
+				"toUsername":        tx.to,
+				"note":              tx.note,
+			},
+			"details": map[string]interface{}{},
+		},
+	})
+	s.broadcast(func(l *listener) []byte {
+		if !l.wallet {
+			return nil
+		}
+		return out
+	})
+}
@@ -1,26 +1,39 @@
 package keybase
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 )
 
 // kvAPIOut sends a JSON request to the kvstore API and returns its response.
-func kvAPIOut(k *Keybase, kv KVAPI) (KVAPI, error) {
+func kvAPIOut(ctx context.Context, k *Keybase, kv KVAPI) (KVAPI, error) {
+	if err := k.checkSupported("kvstore", kv.Method); err != nil {
+		return KVAPI{}, err
+	}
+	var r KVAPI
+	err := k.retry(ctx, "kvstore", kv.Method, func(ctx context.Context) (err error) {
+		r, err = kvAPICall(ctx, k, kv)
+		return err
+	})
+	return r, err
+}
+
+// kvAPICall sends a single JSON request to the kvstore API
+func kvAPICall(ctx context.Context, k *Keybase, kv KVAPI) (KVAPI, error) {
 	jsonBytes, _ := json.Marshal(kv)
 
-	cmdOut, err := k.Exec("kvstore", "api", "-m", string(jsonBytes))
+	cmdOut, err := k.api(ctx, "kvstore", kv.Method, jsonBytes)
 	if err != nil {
-		return KVAPI{}, err
+		return KVAPI{}, withMethod(err, kv.Method)
 	}
 
 	var r KVAPI
 	if err := json.Unmarshal(cmdOut, &r); err != nil {
-		return KVAPI{}, err
+		return KVAPI{}, decodeError(kv.Method, err)
 	}
 
 	if r.Error != nil {
-		return KVAPI{}, errors.New(r.Error.Message)
+		return KVAPI{}, newResponseError(kv.Method, *r.Error)
 	}
 
 	return r, nil
@@ -28,16 +41,21 @@ func kvAPIOut(k *Keybase, kv KVAPI) (KVAPI, error) {
 
 // Namespaces returns all namespaces for a team
 func (kv KV) Namespaces() (KVAPI, error) {
+	return kv.NamespacesContext(context.Background())
+}
+
+// NamespacesContext is like Namespaces, but aborts the request when ctx is done
+func (kv KV) NamespacesContext(ctx context.Context) (KVAPI, error) {
 	m := KVAPI{
-		Params: &kvParams{},
+		Params: &KVParams{},
 	}
-	m.Params.Options = kvOptions{
+	m.Params.Options = KVOptions{
 		Team: kv.Team,
 	}
 
 	m.Method = "list"
 
-	r, err := kvAPIOut(kv.keybase, m)
+	r, err := kvAPIOut(ctx, kv.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -46,17 +64,22 @@ func (kv KV) Namespaces() (KVAPI, error) {
 
 // Keys returns all non-deleted keys for a namespace
 func (kv KV) Keys(namespace string) (KVAPI, error) {
+	return kv.KeysContext(context.Background(), namespace)
+}
+
+// KeysContext is like Keys, but aborts the request when ctx is done
+func (kv KV) KeysContext(ctx context.Context, namespace string) (KVAPI, error) {
 	m := KVAPI{
-		Params: &kvParams{},
+		Params: &KVParams{},
 	}
-	m.Params.Options = kvOptions{
+	m.Params.Options = KVOptions{
 		Team:      kv.Team,
 		Namespace: namespace,
 	}
 
 	m.Method = "list"
 
-	r, err := kvAPIOut(kv.keybase, m)
+	r, err := kvAPIOut(ctx, kv.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -65,10 +88,15 @@ func (kv KV) Keys(namespace string) (KVAPI, error) {
 
 // Get returns an entry
 func (kv KV) Get(namespace string, key string, revision ...uint) (KVAPI, error) {
+	return kv.GetContext(context.Background(), namespace, key, revision...)
+}
+
+// GetContext is like Get, but aborts the request when ctx is done
+func (kv KV) GetContext(ctx context.Context, namespace string, key string, revision ...uint) (KVAPI, error) {
 	m := KVAPI{
-		Params: &kvParams{},
+		Params: &KVParams{},
 	}
-	m.Params.Options = kvOptions{
+	m.Params.Options = KVOptions{
 		Team:      kv.Team,
 		Namespace: namespace,
 		EntryKey:  key,
@@ -80,7 +108,7 @@ func (kv KV) Get(namespace string, key string, revision ...uint) (KVAPI, error)
 
 	m.Method = "get"
 
-	r, err := kvAPIOut(kv.keybase, m)
+	r, err := kvAPIOut(ctx, kv.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -89,10 +117,15 @@ func (kv KV) Get(namespace string, key string, revision ...uint) (KVAPI, error)
 
 // Put adds an entry
 func (kv KV) Put(namespace string, key string, value string, revision ...uint) (KVAPI, error) {
+	return kv.PutContext(context.Background(), namespace, key, value, revision...)
+}
+
+// PutContext is like Put, but aborts the request when ctx is done
+func (kv KV) PutContext(ctx context.Context, namespace string, key string, value string, revision ...uint) (KVAPI, error) {
 	m := KVAPI{
-		Params: &kvParams{},
+		Params: &KVParams{},
 	}
-	m.Params.Options = kvOptions{
+	m.Params.Options = KVOptions{
 		Team:       kv.Team,
 		Namespace:  namespace,
 		EntryKey:   key,
@@ -105,7 +138,7 @@ func (kv KV) Put(namespace string, key string, value string, revision ...uint) (
 
 	m.Method = "put"
 
-	r, err := kvAPIOut(kv.keybase, m)
+	r, err := kvAPIOut(ctx, kv.keybase, m)
 	if err != nil {
 		return r, err
 	}
@@ -114,10 +147,15 @@ func (kv KV) Put(namespace string, key string, value string, revision ...uint) (
 
 // Delete removes an entry
 func (kv KV) Delete(namespace string, key string, revision ...uint) (KVAPI, error) {
+	return kv.DeleteContext(context.Background(), namespace, key, revision...)
+}
+
+// DeleteContext is like Delete, but aborts the request when ctx is done
+func (kv KV) DeleteContext(ctx context.Context, namespace string, key string, revision ...uint) (KVAPI, error) {
 	m := KVAPI{
-		Params: &kvParams{},
+		Params: &KVParams{},
 	}
-	m.Params.Options = kvOptions{
+	m.Params.Options = KVOptions{
 		Team:      kv.Team,
 		Namespace: namespace,
 		EntryKey:  key,
@@ -129,7 +167,7 @@ func (kv KV) Delete(namespace string, key string, revision ...uint) (KVAPI, erro
 
 	m.Method = "del"
 
-	r, err := kvAPIOut(kv.keybase, m)
+	r, err := kvAPIOut(ctx, kv.keybase, m)
 	if err != nil {
 		return r, err
 	}
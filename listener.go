@@ -0,0 +1,172 @@
+package keybase
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// errListenerExited is reported when `chat api-listen` exits without an error
+var errListenerExited = errors.New("keybase: chat api-listen exited")
+
+// Defaults for the RunOptions that control `chat api-listen`
+const (
+	defaultMaxLineSize     = 16 << 20
+	defaultRestartDelay    = time.Second
+	defaultMaxRestartDelay = time.Minute
+)
+
+// Types of the lifecycle events passed to Run's handler if
+// RunOptions.ListenerEvents is set. The event's details are in
+// ChatAPI.Listener.
+const (
+	ListenerStarted    = "listener-started"    // `chat api-listen` was started
+	ListenerExited     = "listener-exited"     // `chat api-listen` exited, or couldn't be started
+	ListenerRestarting = "listener-restarting" // `chat api-listen` is about to be started again
+)
+
+// ListenerEvent describes a change in the state of the `chat api-listen`
+// process started by Run
+type ListenerEvent struct {
+	Type     string        // ListenerStarted, ListenerExited or ListenerRestarting
+	Restarts int           // Number of times the listener was restarted before the run the event is about. 0 for the first run
+	Err      error         // Why the listener exited. Set for ListenerExited
+	Stderr   string        // What the listener wrote to stderr before exiting, if its Process captures it. Set for ListenerExited
+	Delay    time.Duration // How long until the listener is started again. Set for ListenerRestarting
+}
+
+// listener runs `chat api-listen` for RunContext, restarting it with an
+// exponential backoff whenever it exits
+type listener struct {
+	k       *Keybase
+	c       chan<- ChatAPI
+	args    []string
+	events  bool
+	maxLine int
+
+	restartDelay    time.Duration
+	maxRestartDelay time.Duration
+}
+
+// newListener returns a listener that sends what it receives to c
+func newListener(k *Keybase, c chan<- ChatAPI, args []string, opts RunOptions) *listener {
+	l := &listener{
+		k:               k,
+		c:               c,
+		args:            append([]string{"chat", "api-listen"}, args...),
+		events:          opts.ListenerEvents,
+		maxLine:         defaultMaxLineSize,
+		restartDelay:    defaultRestartDelay,
+		maxRestartDelay: defaultMaxRestartDelay,
+	}
+	if opts.MaxLineSize > 0 {
+		l.maxLine = opts.MaxLineSize
+	}
+	if opts.RestartDelay > 0 {
+		l.restartDelay = opts.RestartDelay
+	}
+	if opts.MaxRestartDelay > 0 {
+		l.maxRestartDelay = opts.MaxRestartDelay
+	}
+	if l.maxRestartDelay < l.restartDelay {
+		l.maxRestartDelay = l.restartDelay
+	}
+	return l
+}
+
+// run runs `chat api-listen` until ctx is done
+func (l *listener) run(ctx context.Context) {
+	metrics := l.k.getMetrics()
+	delay := l.restartDelay
+	for restarts := 0; ; restarts++ {
+		if restarts > 0 {
+			metrics.ListenerRestarted()
+			l.emit(ctx, ListenerRestarting, ListenerEvent{Restarts: restarts, Delay: delay})
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		start := time.Now()
+		stderr, err := l.listen(ctx, restarts)
+		if ctx.Err() != nil {
+			return
+		}
+		l.k.logf("keybase: chat api-listen exited: %v", err)
+		l.emit(ctx, ListenerExited, ListenerEvent{Restarts: restarts, Err: err, Stderr: stderr})
+
+		// Back off while the listener keeps failing, but start over once it
+		// has been running for a while
+		if time.Since(start) >= l.maxRestartDelay {
+			delay = l.restartDelay
+		} else if restarts > 0 {
+			delay *= 2
+			if delay > l.maxRestartDelay {
+				delay = l.maxRestartDelay
+			}
+		}
+	}
+}
+
+// listen runs `chat api-listen` once, and returns what it wrote to stderr
+// along with why it exited
+func (l *listener) listen(ctx context.Context, restarts int) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	proc, err := l.k.executor().Start(ctx, l.args...)
+	if err != nil {
+		return "", newExecError(l.args, err)
+	}
+	l.emit(ctx, ListenerStarted, ListenerEvent{Restarts: restarts})
+
+	metrics := l.k.getMetrics()
+	scanner := bufio.NewScanner(proc.Stdout())
+	scanner.Buffer(nil, l.maxLine)
+	for scanner.Scan() {
+		metrics.MessageReceived()
+		var jsonData ChatAPI
+		json.Unmarshal(scanner.Bytes(), &jsonData)
+		if jsonData.ErrorRaw != nil {
+			var errorListen = string(*jsonData.ErrorRaw)
+			jsonData.ErrorListen = &errorListen
+		}
+		select {
+		case l.c <- jsonData:
+			metrics.SetQueueDepth(len(l.c))
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// The process can't be left running once its output isn't read anymore,
+	// e.g. because a line was too long
+	scanErr := scanner.Err()
+	cancel()
+	err = proc.Wait()
+	if scanErr != nil {
+		err = scanErr
+	} else if err == nil {
+		err = errListenerExited
+	}
+	return processStderr(proc), err
+}
+
+// emit passes a lifecycle event to the handler, if it asked for them
+func (l *listener) emit(ctx context.Context, typ string, e ListenerEvent) {
+	if !l.events {
+		return
+	}
+	e.Type = typ
+	select {
+	case l.c <- ChatAPI{Type: typ, Listener: &e}:
+	case <-ctx.Done():
+	}
+}
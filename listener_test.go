@@ -0,0 +1,120 @@
+package keybase_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestListenerRestarts(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan keybase.ChatAPI, 100)
+	go k.RunContext(ctx, func(m keybase.ChatAPI) { events <- m }, keybase.RunOptions{
+		ListenerEvents:  true,
+		MaxHandlers:     1, // Keeps the events in order
+		RestartDelay:    10 * time.Millisecond,
+		MaxRestartDelay: 20 * time.Millisecond,
+		MaxLineSize:     1024,
+	})
+	next := func(typ string) keybase.ChatAPI {
+		t.Helper()
+		select {
+		case m := <-events:
+			if m.Type != typ {
+				t.Fatalf("got %q event, want %q", m.Type, typ)
+			}
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %q event", typ)
+		}
+		return keybase.ChatAPI{}
+	}
+
+	next(keybase.ListenerStarted)
+	srv.KillListeners(errors.New("boom"))
+	exited := next(keybase.ListenerExited).Listener
+	if exited.Err == nil || exited.Err.Error() != "boom" || exited.Stderr != "boom\n" {
+		t.Errorf("got %+v", exited)
+	}
+
+	// The delay between restarts doubles up to MaxRestartDelay
+	for i, want := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond} {
+		restarting := next(keybase.ListenerRestarting).Listener
+		if restarting.Restarts != i+1 || restarting.Delay != want {
+			t.Errorf("got %+v, want restart %d after %s", restarting, i+1, want)
+		}
+		next(keybase.ListenerStarted)
+		if i < 2 {
+			srv.KillListeners(errors.New("boom"))
+			next(keybase.ListenerExited)
+		}
+	}
+
+	// Messages longer than MaxLineSize make the listener restart instead of
+	// stalling it
+	channel := keybase.Channel{Name: "alice,bot"}
+	srv.InjectMessage(channel, "alice", strings.Repeat("x", 2048))
+	if err := next(keybase.ListenerExited).Listener.Err; !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got %v, want bufio.ErrTooLong", err)
+	}
+	next(keybase.ListenerRestarting)
+	next(keybase.ListenerStarted)
+	srv.InjectMessage(channel, "alice", "ping")
+	if m := next("chat"); m.Msg == nil || m.Msg.Content.Text.Body != "ping" {
+		t.Errorf("got %+v", m)
+	}
+}
+
+// failingExecutor fails to start any command
+type failingExecutor struct{}
+
+func (failingExecutor) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return nil, errors.New("no keybase here")
+}
+
+func (failingExecutor) Start(ctx context.Context, args ...string) (keybase.Process, error) {
+	return nil, errors.New("no keybase here")
+}
+
+func TestListenerStartFails(t *testing.T) {
+	k := &keybase.Keybase{Executor: failingExecutor{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan keybase.ChatAPI, 100)
+	go k.RunContext(ctx, func(m keybase.ChatAPI) { events <- m }, keybase.RunOptions{
+		ListenerEvents:  true,
+		MaxHandlers:     1, // Keeps the events in order
+		RestartDelay:    10 * time.Millisecond,
+		MaxRestartDelay: 40 * time.Millisecond,
+	})
+
+	// A listener that can't be started backs off instead of spinning
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+	for i := 0; i < len(want); {
+		select {
+		case m := <-events:
+			switch m.Type {
+			case keybase.ListenerStarted:
+				t.Fatal("listener started")
+			case keybase.ListenerRestarting:
+				if m.Listener.Restarts != i+1 || m.Listener.Delay != want[i] {
+					t.Errorf("got %+v, want restart %d after %s", m.Listener, i+1, want[i])
+				}
+				i++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no restart %d", i+1)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package keybase
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements from a Keybase. Implementations must be safe
+// for concurrent use. MetricsRegistry is a ready-made implementation.
+type Metrics interface {
+	// ObserveCall is called after every request to a JSON API, or command run
+	// with Exec. api is "" for commands that weren't sent to a JSON API, in
+	// which case method is the command name, e.g. "status".
+	ObserveCall(api, method string, d time.Duration, err error)
+
+	// SessionRestarted is called when the subprocess of an API session has to
+	// be started again, after it died or was stopped
+	SessionRestarted(api string)
+
+	// ListenerRestarted is called when Run has to start `chat api-listen`
+	// again, after it exited or failed to start
+	ListenerRestarted()
+
+	// MessageReceived is called for every message read from `chat api-listen`
+	MessageReceived()
+
+	// ObserveHandler is called after every call to a Run handler
+	ObserveHandler(d time.Duration)
+
+	// SetQueueDepth is called with the number of messages waiting to be
+	// passed to Run's handler, whenever it changes
+	SetQueueDepth(n int)
+}
+
+// WithMetrics sets the Metrics that receive measurements of k's API calls and
+// listeners
+func WithMetrics(m Metrics) Option {
+	return func(k *Keybase) error {
+		k.metrics = m
+		return nil
+	}
+}
+
+// nopMetrics is used when a Keybase has no Metrics
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveCall(api, method string, d time.Duration, err error) {}
+func (nopMetrics) SessionRestarted(api string)                                {}
+func (nopMetrics) ListenerRestarted()                                         {}
+func (nopMetrics) MessageReceived()                                           {}
+func (nopMetrics) ObserveHandler(d time.Duration)                             {}
+func (nopMetrics) SetQueueDepth(n int)                                        {}
+
+// getMetrics returns k's Metrics, or a Metrics that discards everything
+func (k *Keybase) getMetrics() Metrics {
+	if k.metrics == nil {
+		return nopMetrics{}
+	}
+	return k.metrics
+}
+
+// Upper bounds of the latency histogram buckets, in seconds
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram is a latency histogram with latencyBuckets
+type histogram struct {
+	counts []uint64 // Cumulative count of observations for each bucket
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	s := d.Seconds()
+	for i, le := range latencyBuckets {
+		if s <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += s
+	h.count++
+}
+
+// write writes h in the Prometheus text format
+func (h *histogram) write(w io.Writer, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, le := range latencyBuckets {
+		var n uint64
+		if h.counts != nil {
+			n = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%g\"} %d\n", name, labels, sep, le, n)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, braces(labels), h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, braces(labels), h.count)
+}
+
+// braces wraps non-empty Prometheus labels in braces
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// callKey identifies an API method in a MetricsRegistry
+type callKey struct {
+	api    string
+	method string
+}
+
+func (c callKey) labels() string {
+	return fmt.Sprintf("api=%q,method=%q", c.api, c.method)
+}
+
+// callStats holds the measurements of a single API method
+type callStats struct {
+	calls   uint64
+	errors  uint64
+	latency histogram
+}
+
+// MetricsRegistry is a Metrics that keeps its measurements in memory. It
+// serves them over HTTP in the Prometheus text format:
+//
+//	m := keybase.NewMetricsRegistry()
+//	k, err := keybase.New(keybase.WithMetrics(m))
+//	http.Handle("/metrics", m)
+type MetricsRegistry struct {
+	mu               sync.Mutex
+	calls            map[callKey]*callStats
+	sessionRestarts  map[string]uint64
+	listenerRestarts uint64
+	messagesReceived uint64
+	handlerDuration  histogram
+	queueDepth       int
+}
+
+// NewMetricsRegistry returns an empty MetricsRegistry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		calls:           make(map[callKey]*callStats),
+		sessionRestarts: make(map[string]uint64),
+	}
+}
+
+// ObserveCall implements Metrics
+func (m *MetricsRegistry) ObserveCall(api, method string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := callKey{api, method}
+	s, ok := m.calls[key]
+	if !ok {
+		s = &callStats{}
+		m.calls[key] = s
+	}
+	s.calls++
+	if err != nil {
+		s.errors++
+	}
+	s.latency.observe(d)
+}
+
+// SessionRestarted implements Metrics
+func (m *MetricsRegistry) SessionRestarted(api string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionRestarts[api]++
+}
+
+// ListenerRestarted implements Metrics
+func (m *MetricsRegistry) ListenerRestarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listenerRestarts++
+}
+
+// MessageReceived implements Metrics
+func (m *MetricsRegistry) MessageReceived() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messagesReceived++
+}
+
+// ObserveHandler implements Metrics
+func (m *MetricsRegistry) ObserveHandler(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlerDuration.observe(d)
+}
+
+// SetQueueDepth implements Metrics
+func (m *MetricsRegistry) SetQueueDepth(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueDepth = n
+}
+
+// WritePrometheus writes all measurements to w in the Prometheus text format
+func (m *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	keys := make([]callKey, 0, len(m.calls))
+	for key := range m.calls {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].api != keys[j].api {
+			return keys[i].api < keys[j].api
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	writeHeader(&b, "keybase_api_calls_total", "counter", "Requests sent to keybase.")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "keybase_api_calls_total{%s} %d\n", key.labels(), m.calls[key].calls)
+	}
+	writeHeader(&b, "keybase_api_errors_total", "counter", "Requests sent to keybase that failed.")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "keybase_api_errors_total{%s} %d\n", key.labels(), m.calls[key].errors)
+	}
+	writeHeader(&b, "keybase_api_call_duration_seconds", "histogram", "Latency of requests sent to keybase.")
+	for _, key := range keys {
+		m.calls[key].latency.write(&b, "keybase_api_call_duration_seconds", key.labels())
+	}
+
+	apis := make([]string, 0, len(m.sessionRestarts))
+	for api := range m.sessionRestarts {
+		apis = append(apis, api)
+	}
+	sort.Strings(apis)
+	writeHeader(&b, "keybase_session_restarts_total", "counter", "Restarts of API session subprocesses.")
+	for _, api := range apis {
+		fmt.Fprintf(&b, "keybase_session_restarts_total{api=%q} %d\n", api, m.sessionRestarts[api])
+	}
+
+	writeHeader(&b, "keybase_listener_restarts_total", "counter", "Restarts of the chat api-listen subprocess.")
+	fmt.Fprintf(&b, "keybase_listener_restarts_total %d\n", m.listenerRestarts)
+	writeHeader(&b, "keybase_listener_messages_total", "counter", "Messages received from chat api-listen.")
+	fmt.Fprintf(&b, "keybase_listener_messages_total %d\n", m.messagesReceived)
+	writeHeader(&b, "keybase_listener_handler_duration_seconds", "histogram", "Time spent in Run handlers.")
+	m.handlerDuration.write(&b, "keybase_listener_handler_duration_seconds", "")
+	writeHeader(&b, "keybase_listener_queue_depth", "gauge", "Messages waiting to be passed to the Run handler.")
+	fmt.Fprintf(&b, "keybase_listener_queue_depth %d\n", m.queueDepth)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHeader writes the HELP and TYPE lines of a Prometheus metric
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// ServeHTTP serves all measurements in the Prometheus text format
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
@@ -0,0 +1,73 @@
+package keybase_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	m := keybase.NewMetricsRegistry()
+	k := srv.Keybase(t, keybase.WithMetrics(m))
+	defer k.Close()
+
+	chat := k.NewChat(keybase.Channel{Name: "alice,bot"})
+	if _, err := chat.Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	srv.InjectError("chat", "send", 2504, "not in conversation")
+	if _, err := chat.Send("hello"); err == nil {
+		t.Fatal("injected error wasn't returned")
+	}
+
+	handled := make(chan struct{}, 10)
+	go k.Run(func(keybase.ChatAPI) { handled <- struct{}{} }, keybase.RunOptions{RestartDelay: 10 * time.Millisecond})
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+	srv.KillListeners(errors.New("crashed"))
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+	srv.InjectMessage(chat.Channel, "alice", "ping")
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message wasn't handled")
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		`keybase_api_calls_total{api="chat",method="send"} 2`,
+		`keybase_api_errors_total{api="chat",method="send"} 1`,
+		`keybase_api_call_duration_seconds_count{api="chat",method="send"} 2`,
+		`keybase_api_calls_total{api="",method="status"} 1`,
+		`keybase_listener_restarts_total 1`,
+		`keybase_listener_messages_total 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics don't contain %s:\n%s", want, out)
+		}
+	}
+	waitFor(t, func() bool {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		return strings.Contains(rec.Body.String(), "keybase_listener_handler_duration_seconds_count 1")
+	})
+}
+
+// waitFor waits up to 5 seconds for cond to become true
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
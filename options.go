@@ -0,0 +1,129 @@
+package keybase
+
+import (
+	"errors"
+	"time"
+)
+
+// Option configures a Keybase created with New
+type Option func(*Keybase) error
+
+// Logger receives diagnostic messages from a Keybase. *log.Logger satisfies
+// this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithPath sets the path to the keybase executable. Defaults to "keybase",
+// which is looked up in $PATH.
+func WithPath(path string) Option {
+	return func(k *Keybase) error {
+		if path == "" {
+			return errors.New("keybase: empty path")
+		}
+		k.Path = path
+		return nil
+	}
+}
+
+// WithHome sets the keybase home directory, which is passed to every keybase
+// command as --home. Each home directory has its own config, service and
+// logged-in user, so use a different one for each Keybase that should act as a
+// different user.
+func WithHome(dir string) Option {
+	return func(k *Keybase) error {
+		k.Home = dir
+		return nil
+	}
+}
+
+// WithSocketFile sets the socket used to talk to the keybase service, which
+// is passed to every keybase command as --socket-file. This is only needed if
+// the service was started with a non-default socket.
+func WithSocketFile(path string) Option {
+	return func(k *Keybase) error {
+		k.SocketFile = path
+		return nil
+	}
+}
+
+// WithEnv adds environment variables, in the form "key=value", to every
+// keybase command
+func WithEnv(env ...string) Option {
+	return func(k *Keybase) error {
+		k.Env = append(k.Env, env...)
+		return nil
+	}
+}
+
+// WithTimeout sets the default timeout for keybase commands. It applies to
+// every call whose context doesn't already have a deadline.
+func WithTimeout(d time.Duration) Option {
+	return func(k *Keybase) error {
+		if d < 0 {
+			return errors.New("keybase: negative timeout")
+		}
+		k.Timeout = d
+		return nil
+	}
+}
+
+// WithLogger sets the Logger that receives diagnostic messages
+func WithLogger(l Logger) Option {
+	return func(k *Keybase) error {
+		k.Logger = l
+		return nil
+	}
+}
+
+// WithExecutor sets the Executor used to run keybase commands. The Executor is
+// responsible for applying any home directory, socket or environment settings.
+func WithExecutor(e Executor) Option {
+	return func(k *Keybase) error {
+		k.Executor = e
+		return nil
+	}
+}
+
+// WithoutSessions starts a new subprocess for every API request, instead of
+// sending them through one long-lived subprocess per API as New does by
+// default
+func WithoutSessions() Option {
+	return func(k *Keybase) error {
+		k.Sessions = false
+		return nil
+	}
+}
+
+// WithService makes New start a private keybase service with StartService,
+// instead of expecting one to already be running. The service is stopped by
+// Close.
+func WithService() Option {
+	return func(k *Keybase) error {
+		k.startService = true
+		return nil
+	}
+}
+
+// WithRecorder records every command k runs, along with its output, in r's
+// transcript. The commands are still run by k's own Executor. Use a Replayer
+// to serve the transcript back later:
+//
+//	r, err := keybase.NewReplayer(transcript)
+//	k, err := keybase.New(keybase.WithExecutor(r))
+func WithRecorder(r *Recorder) Option {
+	return func(k *Keybase) error {
+		if r == nil || r.t == nil {
+			return errors.New("keybase: recorder not created by NewRecorder")
+		}
+		k.recorder = r
+		return nil
+	}
+}
+
+// logf sends a diagnostic message to k's Logger, if it has one
+func (k *Keybase) logf(format string, v ...interface{}) {
+	if k.Logger != nil {
+		k.Logger.Printf(format, v...)
+	}
+}
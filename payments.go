@@ -0,0 +1,143 @@
+package keybase
+
+import "time"
+
+// PaymentDirection tells whether a payment was received or sent by the
+// current user
+type PaymentDirection int
+
+// Possible PaymentDirections
+const (
+	PaymentUnknownDirection PaymentDirection = iota
+	PaymentIncoming
+	PaymentOutgoing
+)
+
+// String implements fmt.Stringer
+func (d PaymentDirection) String() string {
+	switch d {
+	case PaymentIncoming:
+		return "incoming"
+	case PaymentOutgoing:
+		return "outgoing"
+	}
+	return "unknown"
+}
+
+// PaymentStatus is the state of a payment, as reported in
+// PaymentSummary.StatusSimplified
+type PaymentStatus int
+
+// Possible PaymentStatuses
+const (
+	PaymentStatusNone PaymentStatus = iota
+	PaymentPending
+	PaymentClaimable
+	PaymentCompleted
+	PaymentError
+	PaymentStatusUnknown
+	PaymentCanceled
+)
+
+// String implements fmt.Stringer
+func (s PaymentStatus) String() string {
+	switch s {
+	case PaymentStatusNone:
+		return "none"
+	case PaymentPending:
+		return "pending"
+	case PaymentClaimable:
+		return "claimable"
+	case PaymentCompleted:
+		return "completed"
+	case PaymentError:
+		return "error"
+	case PaymentCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// Values of PaymentSummary.Delta
+const (
+	balanceDeltaIncrease = 1
+	balanceDeltaDecrease = 2
+)
+
+// PaymentNotification is a Stellar payment received or sent by the current
+// user, as reported by `chat api-listen --wallet`. Set RunOptions.Wallet or
+// RunOptions.PaymentHandler to receive them.
+type PaymentNotification struct {
+	ID        string
+	TxID      string
+	Time      time.Time
+	Direction PaymentDirection
+	Status    PaymentStatus
+	Amount    string // e.g. "5 XLM"
+	AssetCode string // Empty for XLM
+	From      string // Username of the sender, or their account ID if they don't have one
+	To        string // Username of the recipient, or their account ID if they don't have one
+	Note      string
+
+	Summary PaymentSummary // The notification as received from keybase
+	Details PaymentDetails
+}
+
+// Incoming reports whether the payment was received by the current user
+func (p PaymentNotification) Incoming() bool {
+	return p.Direction == PaymentIncoming
+}
+
+// Outgoing reports whether the payment was sent by the current user
+func (p PaymentNotification) Outgoing() bool {
+	return p.Direction == PaymentOutgoing
+}
+
+// Pending reports whether the payment hasn't reached the recipient yet
+func (p PaymentNotification) Pending() bool {
+	return p.Status == PaymentPending || p.Status == PaymentClaimable
+}
+
+// Completed reports whether the payment has reached the recipient
+func (p PaymentNotification) Completed() bool {
+	return p.Status == PaymentCompleted
+}
+
+// PaymentNotification returns the wallet event received by Run, and whether
+// c is a wallet event at all
+func (c ChatAPI) PaymentNotification() (PaymentNotification, bool) {
+	if c.Notification == nil {
+		return PaymentNotification{}, false
+	}
+	s := c.Notification.Summary
+	p := PaymentNotification{
+		ID:        s.ID,
+		TxID:      s.TxID,
+		Time:      s.Timestamp(),
+		Status:    PaymentStatus(s.StatusSimplified),
+		Amount:    s.AmountDescription,
+		AssetCode: s.AssetCode,
+		From:      firstNonEmpty(s.FromUsername, s.FromAccountID),
+		To:        firstNonEmpty(s.ToUsername, s.ToAssertion, s.ToAccountID),
+		Note:      s.Note,
+		Summary:   s,
+		Details:   c.Notification.Details,
+	}
+	switch s.Delta {
+	case balanceDeltaIncrease:
+		p.Direction = PaymentIncoming
+	case balanceDeltaDecrease:
+		p.Direction = PaymentOutgoing
+	}
+	return p, true
+}
+
+// firstNonEmpty returns the first of its arguments that isn't empty
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
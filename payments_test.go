@@ -0,0 +1,65 @@
+package keybase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestPaymentNotifications(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	srv.SetBalance("bot", 10)
+	k := srv.Keybase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	payments := make(chan keybase.PaymentNotification, 2)
+	messages := make(chan keybase.ChatAPI, 2)
+	go k.RunContext(ctx, func(m keybase.ChatAPI) { messages <- m }, keybase.RunOptions{
+		PaymentHandler: func(p keybase.PaymentNotification) { payments <- p },
+	})
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+
+	txID := srv.InjectPayment("alice", 5, "thanks")
+	if _, err := k.NewWallet().SendXLM("carol", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[keybase.PaymentDirection]keybase.PaymentNotification{}
+	for len(got) < 2 {
+		select {
+		case p := <-payments:
+			got[p.Direction] = p
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d of 2 payments", len(got))
+		}
+	}
+	in := got[keybase.PaymentIncoming]
+	if in.TxID != txID || in.From != "alice" || in.To != "bot" || in.Note != "thanks" || in.Amount != "5 XLM" || in.Time.IsZero() || !in.Completed() || in.Pending() {
+		t.Errorf("got incoming payment %+v", in)
+	}
+	if out := got[keybase.PaymentOutgoing]; out.From != "bot" || out.To != "carol" || !out.Outgoing() {
+		t.Errorf("got outgoing payment %+v", out)
+	}
+	select {
+	case m := <-messages:
+		t.Errorf("message handler received %+v", m)
+	default:
+	}
+
+	// Without a PaymentHandler, wallet events are passed to the message handler,
+	// which can decode them with PaymentNotification
+	m := keybase.ChatAPI{Type: "wallet", Notification: &keybase.Notification{
+		Summary: keybase.PaymentSummary{StatusSimplified: 1, Delta: 1, FromAccountID: "GABC"},
+	}}
+	p, ok := m.PaymentNotification()
+	if !ok || !p.Incoming() || !p.Pending() || p.From != "GABC" {
+		t.Errorf("got %+v, %v", p, ok)
+	}
+	if _, ok := (keybase.ChatAPI{Type: "chat"}).PaymentNotification(); ok {
+		t.Error("chat message decoded as a payment")
+	}
+}
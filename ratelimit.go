@@ -0,0 +1,174 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long to wait after a rate-limit error that didn't say when the limit
+// resets
+const rateLimitBackoff = 5 * time.Second
+
+// RateLimit is the state of one of the rate-limit tanks that keybase reports
+// in chat API responses. Each request uses up some of a tank's gas, and
+// requests are rejected while a tank is empty, until it's refilled at Reset.
+type RateLimit struct {
+	Tank     string
+	Capacity int
+	Gas      int
+	Reset    time.Time
+}
+
+// rateLimiter tracks the rate-limit tanks reported by the chat API, and
+// delays requests that would be rejected because a tank is empty
+type rateLimiter struct {
+	mu    sync.Mutex
+	tanks map[string]*RateLimit
+}
+
+// update records the state of the tanks reported in a chat API response
+func (l *rateLimiter) update(limits []RateLimitInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for _, rl := range limits {
+		if rl.Tank == "" {
+			continue
+		}
+		if l.tanks == nil {
+			l.tanks = make(map[string]*RateLimit)
+		}
+		l.tanks[rl.Tank] = &RateLimit{
+			Tank:     rl.Tank,
+			Capacity: rl.Capacity,
+			Gas:      rl.Gas,
+			Reset:    now.Add(time.Duration(rl.Reset) * time.Second),
+		}
+	}
+}
+
+// exhaust marks every tank that hasn't been refilled yet as empty, after a
+// request was rejected. If no such tank is known, a "chat" tank is assumed to
+// be refilled after rateLimitBackoff.
+func (l *rateLimiter) exhaust() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	exhausted := false
+	for _, t := range l.tanks {
+		if t.Reset.After(now) {
+			t.Gas = 0
+			exhausted = true
+		}
+	}
+	if !exhausted {
+		if l.tanks == nil {
+			l.tanks = make(map[string]*RateLimit)
+		}
+		l.tanks["chat"] = &RateLimit{Tank: "chat", Reset: now.Add(rateLimitBackoff)}
+	}
+}
+
+// reserve returns how long to wait until every tank has gas. If there's no
+// need to wait, one unit of gas is taken from every tank, so that concurrent
+// requests don't all assume that the last unit is theirs.
+func (l *rateLimiter) reserve() (wait time.Duration, tank string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for _, t := range l.tanks {
+		if !t.Reset.After(now) {
+			// The tank has been refilled since it was last reported
+			if t.Gas < t.Capacity {
+				t.Gas = t.Capacity
+			}
+			continue
+		}
+		if d := t.Reset.Sub(now); t.Gas <= 0 && d > wait {
+			wait, tank = d, t.Tank
+		}
+	}
+	if wait == 0 {
+		for _, t := range l.tanks {
+			t.Gas--
+		}
+	}
+	return wait, tank
+}
+
+// RateLimits returns the state of the chat API's rate-limit tanks, as of the
+// last response that reported them
+func (k *Keybase) RateLimits() []RateLimit {
+	k.limiter.mu.Lock()
+	defer k.limiter.mu.Unlock()
+	limits := make([]RateLimit, 0, len(k.limiter.tanks))
+	for _, t := range k.limiter.tanks {
+		limits = append(limits, *t)
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Tank < limits[j].Tank })
+	return limits
+}
+
+// WithRateLimitWait sets the longest a chat request waits for an empty
+// rate-limit tank to be refilled. Requests that would have to wait longer fail
+// with ErrRateLimited instead. By default, requests wait for as long as their
+// context allows. A negative d disables waiting.
+func WithRateLimitWait(d time.Duration) Option {
+	return func(k *Keybase) error {
+		k.rateLimitWait = d
+		return nil
+	}
+}
+
+// throttle waits until the chat API's rate limits allow another request for
+// the given method
+func (k *Keybase) throttle(ctx context.Context, method string) error {
+	for {
+		wait, tank := k.limiter.reserve()
+		if wait == 0 {
+			return nil
+		}
+		if k.rateLimitWait < 0 || (k.rateLimitWait > 0 && wait > k.rateLimitWait) {
+			return &APIError{
+				Method:   method,
+				Code:     scChatRateLimit,
+				Message:  fmt.Sprintf("rate limited: %s tank is empty for another %s", tank, wait.Round(time.Second)),
+				ExitCode: -1,
+			}
+		}
+		k.logf("keybase: %s tank is empty, waiting %s", tank, wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// withRateLimits sends a chat API request with send, once the rate limits
+// reported by previous responses allow it. If the request is rejected by the
+// rate limiter anyway, the following requests wait for the limit to reset. The
+// request itself isn't sent again: whether that's safe is up to the
+// RetryPolicy.
+func (k *Keybase) withRateLimits(ctx context.Context, method string, send func() (ChatAPI, error)) (ChatAPI, error) {
+	if err := k.throttle(ctx, method); err != nil {
+		return ChatAPI{}, err
+	}
+
+	r, err := send()
+	k.limiter.update(r.Ratelimits)
+	if r.Result != nil {
+		k.limiter.update(r.Result.Ratelimits)
+	}
+	if errors.Is(err, ErrRateLimited) {
+		k.limiter.exhaust()
+	}
+	return r, err
+}
@@ -0,0 +1,67 @@
+package keybase_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestRateLimits(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	srv.SetRateLimit("chat", 2, time.Second)
+	channel := keybase.Channel{Name: "alice,bot"}
+
+	k := srv.Keybase(t)
+	for i := 0; i < 2; i++ {
+		if _, err := k.NewChat(channel).Send("hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	limits := k.RateLimits()
+	if len(limits) != 1 || limits[0].Tank != "chat" || limits[0].Gas != 0 || limits[0].Capacity != 2 {
+		t.Fatalf("got %+v", limits)
+	}
+
+	// A client that isn't allowed to wait fails without sending the request
+	noWait := srv.Keybase(t, keybase.WithRateLimitWait(-1))
+	if _, err := noWait.NewChat(channel).Send("hello"); err == nil {
+		t.Fatal("request to an empty tank succeeded")
+	}
+	if _, err := noWait.NewChat(channel).Send("hello"); !errors.Is(err, keybase.ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+
+	// The first client knows the tank is empty, and waits for it to refill
+	start := time.Now()
+	if _, err := k.NewChat(channel).Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("request wasn't delayed (took %s)", d)
+	}
+
+	// A client that doesn't know about the limit is rejected without the
+	// request being sent again, and waits for the limit to reset before its
+	// next request
+	if _, err := k.NewChat(channel).Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+	other := srv.Keybase(t)
+	before := len(srv.Messages(channel))
+	if _, err := other.NewChat(channel).Send("rejected"); !errors.Is(err, keybase.ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	start = time.Now()
+	if _, err := other.NewChat(channel).Send("next"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("request wasn't delayed (took %s)", d)
+	}
+	if msgs := srv.Messages(channel); len(msgs) != before+1 || msgs[len(msgs)-1].Body != "next" {
+		t.Errorf("got %d new messages, last %q", len(msgs)-before, msgs[len(msgs)-1].Body)
+	}
+}
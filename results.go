@@ -0,0 +1,268 @@
+package keybase
+
+import (
+	"context"
+	"time"
+)
+
+// The chat methods return a ChatAPI, which holds whatever the chat API
+// responded with. The methods below return the parts of the response that
+// are relevant to a particular request instead, so that callers don't need to
+// know which of ChatAPI's fields are set. The ChatAPI itself remains available
+// in each result's Raw field, for anything they don't cover.
+
+// SendResult is the result of a request that sends a message: Send,
+// SendEphemeral, Reply, Edit, React, Delete and Upload
+type SendResult struct {
+	MessageID int     // ID of the new message
+	OutboxID  string  // ID of the message in the sender's outbox, if reported
+	Raw       ChatAPI // The response the result was taken from
+}
+
+// ReadResult is the result of Read, ReadMessage, Next and Previous
+type ReadResult struct {
+	Messages   []Message
+	Pagination Pagination
+	Raw        ChatAPI // The response the result was taken from
+}
+
+// ListResult is the result of ChatList
+type ListResult struct {
+	Conversations []Conversation
+	Offline       bool    // Whether the list was loaded from the local cache because the server couldn't be reached
+	Raw           ChatAPI // The response the result was taken from
+}
+
+// FlipResult is the result of LoadFlip
+type FlipResult struct {
+	Status FlipStatus
+	Raw    ChatAPI // The response the result was taken from
+}
+
+// DownloadResult is the result of Download
+type DownloadResult struct {
+	Message string
+	Raw     ChatAPI // The response the result was taken from
+}
+
+// SendResult returns the result of a request that sent a message
+func (c ChatAPI) SendResult() SendResult {
+	if c.Result == nil {
+		return SendResult{Raw: c}
+	}
+	return SendResult{
+		MessageID: c.Result.ID,
+		OutboxID:  c.Result.OutboxID,
+		Raw:       c,
+	}
+}
+
+// ReadResult returns the messages read by Read, ReadMessage, Next or Previous
+func (c ChatAPI) ReadResult() ReadResult {
+	if c.Result == nil {
+		return ReadResult{Raw: c}
+	}
+	r := ReadResult{
+		Messages:   make([]Message, 0, len(c.Result.Messages)),
+		Pagination: c.Result.Pagination,
+		Raw:        c,
+	}
+	for _, m := range c.Result.Messages {
+		r.Messages = append(r.Messages, m.Msg)
+	}
+	return r
+}
+
+// ListResult returns the conversations listed by ChatList
+func (c ChatAPI) ListResult() ListResult {
+	if c.Result == nil {
+		return ListResult{Raw: c}
+	}
+	return ListResult{
+		Conversations: c.Result.Conversations,
+		Offline:       c.Result.Offline,
+		Raw:           c,
+	}
+}
+
+// FlipResult returns the state of the flip loaded by LoadFlip
+func (c ChatAPI) FlipResult() FlipResult {
+	if c.Result == nil {
+		return FlipResult{Raw: c}
+	}
+	return FlipResult{Status: c.Result.Status, Raw: c}
+}
+
+// DownloadResult returns the result of Download
+func (c ChatAPI) DownloadResult() DownloadResult {
+	if c.Result == nil {
+		return DownloadResult{Raw: c}
+	}
+	return DownloadResult{Message: c.Result.Message, Raw: c}
+}
+
+// SendResult is like Send, but returns a SendResult
+func (c Chat) SendResult(message ...string) (SendResult, error) {
+	return c.SendResultContext(context.Background(), message...)
+}
+
+// SendResultContext is like SendContext, but returns a SendResult
+func (c Chat) SendResultContext(ctx context.Context, message ...string) (SendResult, error) {
+	r, err := c.SendContext(ctx, message...)
+	return r.SendResult(), err
+}
+
+// SendEphemeralResult is like SendEphemeral, but returns a SendResult
+func (c Chat) SendEphemeralResult(duration time.Duration, message ...string) (SendResult, error) {
+	return c.SendEphemeralResultContext(context.Background(), duration, message...)
+}
+
+// SendEphemeralResultContext is like SendEphemeralContext, but returns a SendResult
+func (c Chat) SendEphemeralResultContext(ctx context.Context, duration time.Duration, message ...string) (SendResult, error) {
+	r, err := c.SendEphemeralContext(ctx, duration, message...)
+	return r.SendResult(), err
+}
+
+// ReplyResult is like Reply, but returns a SendResult
+func (c Chat) ReplyResult(replyTo int, message ...string) (SendResult, error) {
+	return c.ReplyResultContext(context.Background(), replyTo, message...)
+}
+
+// ReplyResultContext is like ReplyContext, but returns a SendResult
+func (c Chat) ReplyResultContext(ctx context.Context, replyTo int, message ...string) (SendResult, error) {
+	r, err := c.ReplyContext(ctx, replyTo, message...)
+	return r.SendResult(), err
+}
+
+// EditResult is like Edit, but returns a SendResult
+func (c Chat) EditResult(messageID int, message ...string) (SendResult, error) {
+	return c.EditResultContext(context.Background(), messageID, message...)
+}
+
+// EditResultContext is like EditContext, but returns a SendResult
+func (c Chat) EditResultContext(ctx context.Context, messageID int, message ...string) (SendResult, error) {
+	r, err := c.EditContext(ctx, messageID, message...)
+	return r.SendResult(), err
+}
+
+// ReactResult is like React, but returns a SendResult
+func (c Chat) ReactResult(messageID int, reaction string) (SendResult, error) {
+	return c.ReactResultContext(context.Background(), messageID, reaction)
+}
+
+// ReactResultContext is like ReactContext, but returns a SendResult
+func (c Chat) ReactResultContext(ctx context.Context, messageID int, reaction string) (SendResult, error) {
+	r, err := c.ReactContext(ctx, messageID, reaction)
+	return r.SendResult(), err
+}
+
+// DeleteResult is like Delete, but returns a SendResult
+func (c Chat) DeleteResult(messageID int) (SendResult, error) {
+	return c.DeleteResultContext(context.Background(), messageID)
+}
+
+// DeleteResultContext is like DeleteContext, but returns a SendResult
+func (c Chat) DeleteResultContext(ctx context.Context, messageID int) (SendResult, error) {
+	r, err := c.DeleteContext(ctx, messageID)
+	return r.SendResult(), err
+}
+
+// UploadResult is like Upload, but returns a SendResult
+func (c Chat) UploadResult(title string, filepath string) (SendResult, error) {
+	return c.UploadResultContext(context.Background(), title, filepath)
+}
+
+// UploadResultContext is like UploadContext, but returns a SendResult
+func (c Chat) UploadResultContext(ctx context.Context, title string, filepath string) (SendResult, error) {
+	r, err := c.UploadContext(ctx, title, filepath)
+	return r.SendResult(), err
+}
+
+// ReadResult is like Read, but returns a ReadResult
+func (c Chat) ReadResult(count ...int) (ReadResult, error) {
+	return c.ReadResultContext(context.Background(), count...)
+}
+
+// ReadResultContext is like ReadContext, but returns a ReadResult
+func (c Chat) ReadResultContext(ctx context.Context, count ...int) (ReadResult, error) {
+	r, err := c.ReadContext(ctx, count...)
+	if r == nil {
+		return ReadResult{}, err
+	}
+	return r.ReadResult(), err
+}
+
+// ReadMessageResult is like ReadMessage, but returns a ReadResult
+func (c Chat) ReadMessageResult(messageID int) (ReadResult, error) {
+	return c.ReadMessageResultContext(context.Background(), messageID)
+}
+
+// ReadMessageResultContext is like ReadMessageContext, but returns a ReadResult
+func (c Chat) ReadMessageResultContext(ctx context.Context, messageID int) (ReadResult, error) {
+	r, err := c.ReadMessageContext(ctx, messageID)
+	if r == nil {
+		return ReadResult{}, err
+	}
+	return r.ReadResult(), err
+}
+
+// NextResult is like Next, but returns a ReadResult
+func (c *ChatAPI) NextResult(count ...int) (ReadResult, error) {
+	return c.NextResultContext(context.Background(), count...)
+}
+
+// NextResultContext is like NextContext, but returns a ReadResult
+func (c *ChatAPI) NextResultContext(ctx context.Context, count ...int) (ReadResult, error) {
+	r, err := c.NextContext(ctx, count...)
+	if r == nil {
+		return ReadResult{}, err
+	}
+	return r.ReadResult(), err
+}
+
+// PreviousResult is like Previous, but returns a ReadResult
+func (c *ChatAPI) PreviousResult(count ...int) (ReadResult, error) {
+	return c.PreviousResultContext(context.Background(), count...)
+}
+
+// PreviousResultContext is like PreviousContext, but returns a ReadResult
+func (c *ChatAPI) PreviousResultContext(ctx context.Context, count ...int) (ReadResult, error) {
+	r, err := c.PreviousContext(ctx, count...)
+	if r == nil {
+		return ReadResult{}, err
+	}
+	return r.ReadResult(), err
+}
+
+// DownloadResult is like Download, but returns a DownloadResult
+func (c Chat) DownloadResult(messageID int, filepath string) (DownloadResult, error) {
+	return c.DownloadResultContext(context.Background(), messageID, filepath)
+}
+
+// DownloadResultContext is like DownloadContext, but returns a DownloadResult
+func (c Chat) DownloadResultContext(ctx context.Context, messageID int, filepath string) (DownloadResult, error) {
+	r, err := c.DownloadContext(ctx, messageID, filepath)
+	return r.DownloadResult(), err
+}
+
+// LoadFlipResult is like LoadFlip, but returns a FlipResult
+func (c Chat) LoadFlipResult(messageID int, conversationID string, flipConversationID string, gameID string) (FlipResult, error) {
+	return c.LoadFlipResultContext(context.Background(), messageID, conversationID, flipConversationID, gameID)
+}
+
+// LoadFlipResultContext is like LoadFlipContext, but returns a FlipResult
+func (c Chat) LoadFlipResultContext(ctx context.Context, messageID int, conversationID string, flipConversationID string, gameID string) (FlipResult, error) {
+	r, err := c.LoadFlipContext(ctx, messageID, conversationID, flipConversationID, gameID)
+	return r.FlipResult(), err
+}
+
+// ChatListResult is like ChatList, but returns a ListResult
+func (k *Keybase) ChatListResult(opts ...Channel) (ListResult, error) {
+	return k.ChatListResultContext(context.Background(), opts...)
+}
+
+// ChatListResultContext is like ChatListContext, but returns a ListResult
+func (k *Keybase) ChatListResultContext(ctx context.Context, opts ...Channel) (ListResult, error) {
+	r, err := k.ChatListContext(ctx, opts...)
+	return r.ListResult(), err
+}
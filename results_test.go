@@ -0,0 +1,56 @@
+package keybase_test
+
+import (
+	"testing"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestResults(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+	chat := k.NewChat(keybase.Channel{Name: "alice,bot"})
+
+	sent, err := chat.SendResult("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.MessageID == 0 || sent.OutboxID == "" || sent.Raw.Result == nil {
+		t.Fatalf("got %+v", sent)
+	}
+
+	read, err := chat.ReadResult(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Messages) != 1 || read.Messages[0].ID != sent.MessageID || read.Messages[0].Content.Text.Body != "hello" {
+		t.Fatalf("got %+v", read.Messages)
+	}
+	if !read.Pagination.Last {
+		t.Errorf("got %+v, want last page", read.Pagination)
+	}
+
+	list, err := k.ChatListResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	convs := list.Conversations
+	if len(convs) != 1 || convs[0].Channel.Name != "alice,bot" {
+		t.Fatalf("got %+v", convs)
+	}
+
+	// The same results can be taken from a ChatAPI
+	page, err := chat.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := page.ReadResult(); len(got.Messages) != 1 || got.Messages[0].ID != sent.MessageID {
+		t.Errorf("got %+v", got)
+	}
+
+	// Results of requests that failed are empty
+	if got, err := chat.EditResult(12345, "x"); err == nil || got.MessageID != 0 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,185 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a transient error are
+// retried. Only idempotent requests, such as Read, ChatList, KV.Get,
+// MemberList and UserLookup, are retried, unless the request's context
+// carries an idempotency key set with WithIdempotencyKey.
+type RetryPolicy struct {
+	MaxAttempts int              // Maximum number of attempts, including the first. Values below 2 disable retries
+	BaseDelay   time.Duration    // Delay before the first retry. Doubled for every following retry
+	MaxDelay    time.Duration    // Upper bound for the delay between attempts (0 = none)
+	Jitter      float64          // Fraction of each delay that is randomized, between 0 and 1
+	Retryable   func(error) bool // Reports whether an error is worth retrying. Defaults to IsTransient
+}
+
+// DefaultRetryPolicy is a reasonable RetryPolicy for bots talking to a local
+// keybase service
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.2,
+}
+
+// WithRetryPolicy sets the RetryPolicy for k's requests. By default, requests
+// aren't retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(k *Keybase) error {
+		if p.Jitter < 0 || p.Jitter > 1 {
+			return errors.New("keybase: retry jitter must be between 0 and 1")
+		}
+		k.retryPolicy = p
+		return nil
+	}
+}
+
+// IsTransient reports whether err is likely to go away if the request is
+// retried: the service is restarting or its socket isn't ready yet, an API
+// session's subprocess died, or the request was rate limited.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrServiceNotRunning):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.ErrClosedPipe):
+		return true
+	case errors.Is(err, syscall.EPIPE), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return true
+	}
+	return false
+}
+
+// delay returns how long to wait before the given retry, starting at 1
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// idempotentMethods lists the requests that can safely be sent more than
+// once, keyed by API, then by method. Commands that aren't sent to a JSON API
+// are listed under "".
+var idempotentMethods = map[string]map[string]bool{
+	"chat": {
+		"list":              true,
+		"read":              true,
+		"searchinbox":       true,
+		"download":          true,
+		"loadflip":          true,
+		"mark":              true,
+		"advertisecommands": true,
+		"clearcommands":     true,
+	},
+	"team": {
+		"list-team-memberships": true,
+		"list-user-memberships": true,
+	},
+	"kvstore": {
+		"list": true,
+		"get":  true,
+	},
+	"wallet": {
+		"lookup":  true,
+		"details": true,
+	},
+	"": {
+		"status":  true,
+		"version": true,
+		"apicall": true,
+	},
+}
+
+// idempotencyKey is the context key for WithIdempotencyKey
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context that allows requests that aren't
+// idempotent, such as Chat.Send and Wallet.Send, to be retried according to
+// the Keybase's RetryPolicy. keybase doesn't deduplicate requests itself, so
+// setting a key declares that the caller can cope with the request being
+// carried out more than once, e.g. because it can recognize duplicates by
+// the key. The key is passed to Hooks as Call.IdempotencyKey.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFrom returns the idempotency key set on ctx, if any
+func IdempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// attemptKey is the context key for the attempt number of a retried request
+type attemptKey struct{}
+
+// retry calls do until it succeeds, fails with an error that isn't worth
+// retrying, or k's RetryPolicy runs out of attempts. Requests that aren't
+// idempotent are only retried if ctx carries an idempotency key.
+//
+// This is the only place requests are sent again. That includes chat requests
+// rejected by the rate limiter, whose next attempt waits for the limit to
+// reset.
+func (k *Keybase) retry(ctx context.Context, api, method string, do func(context.Context) error) error {
+	p := k.retryPolicy
+	if p.MaxAttempts < 2 || (!idempotentMethods[api][method] && IdempotencyKeyFrom(ctx) == "") {
+		return do(ctx)
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := do(context.WithValue(ctx, attemptKey{}, attempt))
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		d := p.delay(attempt)
+		k.logf("keybase: %s failed, retrying in %s: %v", method, d, err)
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// execRetry is like ExecContext, but retries idempotent commands according to
+// k's RetryPolicy
+func (k *Keybase) execRetry(ctx context.Context, command ...string) (out []byte, err error) {
+	err = k.retry(ctx, "", commandName(command), func(ctx context.Context) (err error) {
+		out, err = k.ExecContext(ctx, command...)
+		return err
+	})
+	return out, err
+}
+
+// attemptFrom returns the attempt number of the request using ctx, starting at 1
+func attemptFrom(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,133 @@
+package keybase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{keybase.ErrRateLimited, true},
+		{keybase.ErrServiceNotRunning, true},
+		{&keybase.APIError{Message: "dial unix /run/keybase/keybased.sock: connect: connection refused"}, true},
+		{&keybase.APIError{Method: "chat api", Err: io.EOF}, true},
+		{&keybase.APIError{Code: 2614, Message: "team not found"}, false},
+		{fmt.Errorf("wrapped: %w", keybase.ErrRateLimited), true},
+	}
+	for _, tt := range tests {
+		if got := keybase.IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	const refused = "dial unix keybased.sock: connect: connection refused"
+	policy := keybase.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	srv := keybasetest.NewServer("bot")
+	channel := keybase.Channel{Name: "alice,bot"}
+
+	// Idempotent requests are retried
+	k := srv.Keybase(t, keybase.WithRetryPolicy(policy))
+	srv.InjectError("chat", "read", 0, refused)
+	srv.InjectError("chat", "read", 0, refused)
+	if _, err := k.NewChat(channel).Read(); err != nil {
+		t.Fatalf("Read wasn't retried: %v", err)
+	}
+
+	// ... but only up to MaxAttempts times
+	for i := 0; i < 3; i++ {
+		srv.InjectError("kvstore", "get", 0, refused)
+	}
+	if _, err := k.NewKV("").Get("ns", "key"); !errors.Is(err, keybase.ErrServiceNotRunning) {
+		t.Fatalf("got %v, want ErrServiceNotRunning", err)
+	}
+
+	// Permanent errors aren't retried
+	srv.InjectError("team", "list-team-memberships", 2614, "team not found")
+	srv.AddTeam("acme", nil)
+	if _, err := k.NewTeam("acme").MemberList(); !errors.Is(err, keybase.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	// Requests that aren't idempotent are only retried with an idempotency key
+	srv.InjectError("chat", "send", 0, refused)
+	if _, err := k.NewChat(channel).Send("hello"); err == nil {
+		t.Fatal("Send was retried without an idempotency key")
+	}
+	srv.InjectError("chat", "send", 0, refused)
+	ctx := keybase.WithIdempotencyKey(context.Background(), "msg-1")
+	if _, err := k.NewChat(channel).SendContext(ctx, "hello"); err != nil {
+		t.Fatalf("Send with an idempotency key wasn't retried: %v", err)
+	}
+	if n := len(srv.Messages(channel)); n != 1 {
+		t.Errorf("got %d messages, want 1", n)
+	}
+
+	// Without a policy, nothing is retried
+	k = srv.Keybase(t)
+	srv.InjectError("chat", "read", 0, refused)
+	if _, err := k.NewChat(channel).Read(); err == nil {
+		t.Fatal("Read was retried without a RetryPolicy")
+	}
+}
+
+func TestRetryRateLimits(t *testing.T) {
+	policy := keybase.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	srv := keybasetest.NewServer("bot")
+	srv.SetRateLimit("chat", 1, time.Second)
+	channel := keybase.Channel{Name: "alice,bot"}
+	sends := func(h *recordingHook) (n int) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, call := range h.calls {
+			if call.Method == "send" {
+				n++
+			}
+		}
+		return n
+	}
+
+	if _, err := srv.Keybase(t).NewChat(channel).Send("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rate-limited Send isn't sent again without an idempotency key
+	hook := &recordingHook{}
+	k := srv.Keybase(t, keybase.WithRetryPolicy(policy), keybase.WithHooks(hook))
+	if _, err := k.NewChat(channel).Send("rejected"); !errors.Is(err, keybase.ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	if n := sends(hook); n != 1 {
+		t.Errorf("Send was attempted %d times, want 1", n)
+	}
+
+	// With a key, it's retried by the RetryPolicy once the limit resets, and
+	// nowhere else
+	hook = &recordingHook{}
+	k = srv.Keybase(t, keybase.WithRetryPolicy(policy), keybase.WithHooks(hook))
+	ctx := keybase.WithIdempotencyKey(context.Background(), "msg-1")
+	if _, err := k.NewChat(channel).SendContext(ctx, "retried"); err != nil {
+		t.Fatal(err)
+	}
+	if n := sends(hook); n != 2 {
+		t.Errorf("Send was attempted %d times, want 2", n)
+	}
+	if msgs := srv.Messages(channel); len(msgs) != 2 || msgs[1].Body != "retried" {
+		t.Errorf("got messages %+v", msgs)
+	}
+}
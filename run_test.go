@@ -0,0 +1,90 @@
+package keybase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestRunContext(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+	channel := keybase.Channel{Name: "alice,bot"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- k.RunContext(ctx, func(m keybase.ChatAPI) {
+			close(started)
+			<-release
+			close(finished)
+		})
+	}()
+
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+	srv.InjectMessage(channel, "alice", "ping")
+	<-started
+
+	// RunContext waits for the running handler before returning
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("RunContext returned %v while a handler was running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext didn't return after its context was cancelled")
+	}
+	<-finished
+	if n := srv.Listeners(); n != 0 {
+		t.Errorf("%d listeners still running", n)
+	}
+
+	// Handlers that don't return within the drain timeout are abandoned
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stuck := make(chan struct{})
+	defer close(stuck)
+	go func() {
+		done <- k.RunContext(ctx, func(m keybase.ChatAPI) {
+			cancel()
+			<-stuck
+		}, keybase.RunOptions{DrainTimeout: 50 * time.Millisecond})
+	}()
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+	srv.InjectMessage(channel, "alice", "ping")
+	select {
+	case err := <-done:
+		if err == context.Canceled || !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want a drain timeout wrapping context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext didn't give up on a stuck handler")
+	}
+
+	// Close stops RunContext too
+	go func() { done <- k.RunContext(context.Background(), func(keybase.ChatAPI) {}) }()
+	waitFor(t, func() bool { return srv.Listeners() == 1 })
+	k.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, keybase.ErrClosed) {
+			t.Errorf("got %v, want ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext didn't return after Close")
+	}
+}
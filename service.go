@@ -0,0 +1,127 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// How often StartService checks whether the service has come up
+const servicePollInterval = 250 * time.Millisecond
+
+// How long New waits for a service started with WithService to come up, if no
+// Timeout is set
+const serviceStartTimeout = 30 * time.Second
+
+// How long Close waits for a service started by StartService to shut down
+const serviceStopTimeout = 10 * time.Second
+
+// ErrServiceRunning is returned by StartService when k already manages a
+// running service
+var ErrServiceRunning = errors.New("keybase: service already started")
+
+// managedService is a `keybase service` process started by StartService
+type managedService struct {
+	proc   Process
+	cancel context.CancelFunc
+	exited chan struct{} // Closed once proc has exited
+	err    error         // Set to the result of proc.Wait() before exited is closed
+}
+
+// StartService starts a private keybase service by running `keybase service`,
+// using k's home directory and environment, and waits until `keybase status`
+// reports that it's running. The service keeps running until StopService or
+// Close is called. ctx only bounds how long to wait for the service to start.
+func (k *Keybase) StartService(ctx context.Context) error {
+	k.mu.Lock()
+	if k.service != nil {
+		k.mu.Unlock()
+		return ErrServiceRunning
+	}
+
+	svcCtx, cancel := context.WithCancel(context.Background())
+	proc, err := k.executor().Start(svcCtx, "service")
+	if err != nil {
+		k.mu.Unlock()
+		cancel()
+		return err
+	}
+	svc := &managedService{
+		proc:   proc,
+		cancel: cancel,
+		exited: make(chan struct{}),
+	}
+	// Nothing uses the service's stdout, but it has to be read so that the
+	// service doesn't block once the pipe is full
+	go io.Copy(ioutil.Discard, proc.Stdout())
+	go func() {
+		svc.err = proc.Wait()
+		close(svc.exited)
+	}()
+	k.service = svc
+	k.mu.Unlock()
+	k.logf("keybase: started service")
+
+	if err := k.waitForService(ctx, svc); err != nil {
+		stopCtx, cancel := context.WithTimeout(context.Background(), serviceStopTimeout)
+		defer cancel()
+		k.StopService(stopCtx)
+		return err
+	}
+	return k.refresh(ctx)
+}
+
+// waitForService polls `keybase status` until the service is running. If it
+// doesn't come up, the error reports what the service wrote to stderr, if its
+// Process captures it.
+func (k *Keybase) waitForService(ctx context.Context, svc *managedService) error {
+	ticker := time.NewTicker(servicePollInterval)
+	defer ticker.Stop()
+	for {
+		s, err := k.StatusContext(ctx)
+		if err == nil && s.Service.Running {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-svc.exited:
+			if svc.err != nil {
+				return &APIError{Method: "service", ExitCode: -1, Err: svc.err, Stderr: processStderr(svc.proc)}
+			}
+			return ErrServiceNotRunning
+		case <-ctx.Done():
+			return &APIError{Method: "service", ExitCode: -1, Err: ctx.Err(), Stderr: processStderr(svc.proc)}
+		}
+	}
+}
+
+// StopService stops a service started by StartService. It asks the service to
+// shut down with `keybase ctl stop`, and kills it if it hasn't exited by the
+// time ctx is done. It does nothing if k isn't managing a service.
+func (k *Keybase) StopService(ctx context.Context) error {
+	k.mu.Lock()
+	svc := k.service
+	k.service = nil
+	k.mu.Unlock()
+	if svc == nil {
+		return nil
+	}
+
+	k.closeSessions()
+	_, err := k.ExecContext(ctx, "ctl", "stop")
+	select {
+	case <-svc.exited:
+	case <-ctx.Done():
+	}
+	svc.cancel()
+	<-svc.exited
+	k.logf("keybase: stopped service")
+
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
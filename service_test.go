@@ -0,0 +1,64 @@
+package keybase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStartStopService(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"status -j": `{"Username":"bot","LoggedIn":true,"Service":{"Running":true}}`,
+		"ctl stop":  ``,
+	}}
+	k := &Keybase{Executor: e}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := k.StartService(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.StartService(ctx); !errors.Is(err, ErrServiceRunning) {
+		t.Errorf("second StartService() error = %v, want %v", err, ErrServiceRunning)
+	}
+	if !k.LoggedIn || k.Username != "bot" {
+		t.Errorf("status not refreshed: %+v", k)
+	}
+
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	calls, _ := e.history()
+	last := calls[len(calls)-1]
+	if len(last) != 2 || last[0] != "ctl" || last[1] != "stop" {
+		t.Errorf("last command = %q, want [ctl stop]", last)
+	}
+	if k.service != nil {
+		t.Error("service still set after Close")
+	}
+}
+
+func TestStartServiceTimeout(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{
+		"status -j": `{"Service":{"Running":false}}`,
+		"ctl stop":  ``,
+	}}
+	e.stderr = "can't bind socket\n"
+	k := &Keybase{Executor: e}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := k.StartService(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("StartService() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// What the service wrote to stderr is reported
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Stderr != e.stderr {
+		t.Errorf("StartService() error = %#v, want stderr %q", err, e.stderr)
+	}
+	if k.service != nil {
+		t.Error("service still set after failed start")
+	}
+}
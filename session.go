@@ -0,0 +1,236 @@
+package keybase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+)
+
+// errNoStdin is returned by session.call if the Executor's Processes don't
+// have a stdin that requests can be written to
+var errNoStdin = errors.New("keybase: process has no stdin")
+
+// session is a long-lived `keybase <family> api` process. Requests are written
+// to its stdin as JSON, and responses are read back from its stdout in the
+// same order. Only one request is in flight at a time.
+type session struct {
+	k      *Keybase
+	family string
+
+	mu      sync.Mutex
+	proc    Process
+	stdin   io.WriteCloser
+	dec     *json.Decoder
+	cancel  context.CancelFunc
+	exited  chan struct{} // Closed when the subprocess exits
+	started bool          // Whether the subprocess has been started before
+	noStdin bool          // Whether the Executor's Processes turned out not to have a stdin
+}
+
+// start starts the session's subprocess. s.mu must be held.
+func (s *session) start() error {
+	if s.noStdin {
+		return errNoStdin
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	proc, err := s.k.executor().Start(ctx, s.family, "api")
+	if err != nil {
+		cancel()
+		return err
+	}
+	stdin := processStdin(proc)
+	if stdin == nil {
+		cancel()
+		proc.Wait()
+		s.noStdin = true
+		return errNoStdin
+	}
+	s.k.logf("keybase: started %s api session", s.family)
+	if s.started {
+		s.k.getMetrics().SessionRestarted(s.family)
+	}
+	s.started = true
+	s.proc = proc
+	s.stdin = stdin
+	s.dec = json.NewDecoder(proc.Stdout())
+	s.cancel = cancel
+	s.exited = make(chan struct{})
+	go func(exited chan struct{}) {
+		proc.Wait()
+		close(exited)
+	}(s.exited)
+	return nil
+}
+
+// hasExited reports whether the session's subprocess has exited since it was
+// started. s.mu must be held.
+func (s *session) hasExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop kills the session's subprocess, if it is running. s.mu must be held.
+func (s *session) stop() {
+	if s.proc == nil {
+		return
+	}
+	s.stdin.Close()
+	s.cancel()
+	<-s.exited
+	s.proc = nil
+	s.stdin = nil
+	s.dec = nil
+	s.cancel = nil
+	s.exited = nil
+}
+
+// call sends a single JSON request through the session and returns the raw
+// response. If the subprocess has died, e.g. because the service restarted
+// while the session was idle, it is restarted first. If it turns out to have
+// died before it read the request, it is restarted and the request is sent
+// once more. If anything else goes wrong mid-request, the subprocess is killed
+// so that the next call starts from a clean slate.
+func (s *session) call(ctx context.Context, req []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.proc != nil && s.hasExited() {
+		s.k.logf("keybase: %s api session exited", s.family)
+		s.stop()
+	}
+	for attempt := 1; ; attempt++ {
+		if s.proc == nil {
+			if err := s.start(); err != nil {
+				return nil, err
+			}
+		}
+		out, unread, err := s.send(ctx, req)
+		if err != nil && unread && attempt == 1 {
+			s.k.logf("keybase: %s api session died before reading the request, restarting it: %v", s.family, err)
+			continue
+		}
+		return out, err
+	}
+}
+
+// send writes a request to the session's subprocess and reads back its
+// response. If it fails, the subprocess is stopped, and unread reports whether
+// the subprocess died before it could have read the request: writing to it
+// failed with a broken pipe, or its stdout ended before any response. s.mu
+// must be held.
+func (s *session) send(ctx context.Context, req []byte) (out []byte, unread bool, err error) {
+	type response struct {
+		raw json.RawMessage
+		err error
+	}
+	// The response is read while the request is being written, because the
+	// subprocess may answer before it has read all of the request, such as
+	// its trailing newline
+	done := make(chan response, 1)
+	written := make(chan error, 1)
+	stdin, dec := s.stdin, s.dec
+	line := append(append([]byte(nil), req...), '\n')
+	go func() {
+		_, err := stdin.Write(line)
+		written <- err
+	}()
+	go func() {
+		var r response
+		r.err = dec.Decode(&r.raw)
+		done <- r
+	}()
+
+	fail := func(err error) error {
+		s.k.logf("keybase: %s api session failed: %v", s.family, err)
+		s.stop()
+		return &APIError{Method: s.family + " api", ExitCode: -1, Err: err}
+	}
+	// The write has to finish before the next request is written to the same
+	// stdin, so it's waited for even once the response has been read
+	var r *response
+	for wch := written; wch != nil || r == nil; {
+		select {
+		case werr := <-wch:
+			wch = nil
+			if werr != nil {
+				return nil, isBrokenPipe(werr), fail(werr)
+			}
+		case resp := <-done:
+			if resp.err != nil {
+				return nil, resp.err == io.EOF, fail(resp.err)
+			}
+			r = &resp
+		case <-ctx.Done():
+			s.stop()
+			return nil, false, ctx.Err()
+		}
+	}
+	return r.raw, false, nil
+}
+
+// isBrokenPipe reports whether err is the error returned by writing to a
+// process that has closed its stdin
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
+
+// close stops the session's subprocess
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+}
+
+// apiSession returns the session for the given API family, creating it if
+// necessary
+func (k *Keybase) apiSession(family string) *session {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.sessions == nil {
+		k.sessions = make(map[string]*session)
+	}
+	s, ok := k.sessions[family]
+	if !ok {
+		s = &session{k: k, family: family}
+		k.sessions[family] = s
+	}
+	return s
+}
+
+// closeSessions stops all of k's API sessions. They are restarted by the next
+// request that needs them.
+func (k *Keybase) closeSessions() {
+	k.mu.Lock()
+	sessions := k.sessions
+	k.sessions = nil
+	k.mu.Unlock()
+	for _, s := range sessions {
+		s.close()
+	}
+}
+
+// api sends a JSON request for the given method to `keybase <family> api` and
+// returns the raw response. If k.Sessions is set, the request is sent through
+// a long-lived subprocess; otherwise, or if the Executor's Processes don't
+// have a stdin, a new one is started for the request.
+func (k *Keybase) api(ctx context.Context, family, method string, req []byte) ([]byte, error) {
+	call := &Call{API: family, Method: method, Request: req}
+	return k.observe(ctx, call, func(ctx context.Context) ([]byte, error) {
+		if k.Sessions {
+			sctx, cancel := k.withTimeout(ctx)
+			out, err := k.apiSession(family).call(sctx, req)
+			cancel()
+			if err != errNoStdin {
+				return out, err
+			}
+		}
+		return k.exec(ctx, []string{family, "api", "-m", string(req)})
+	})
+}
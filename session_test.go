@@ -0,0 +1,240 @@
+package keybase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echoExecutor starts `<family> api` sessions that answer every request with
+// {"echo": <request>}. Requests for the "die" method make the session exit
+// without answering, and requests for the "hang" method are never answered.
+// Requests for the "bye" method make the session exit after answering, and
+// requests for the "deaf" method make it close its stdin after answering,
+// without exiting.
+type echoExecutor struct {
+	mu     sync.Mutex
+	starts int
+}
+
+func (e *echoExecutor) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return nil, fmt.Errorf("unexpected command %q", args)
+}
+
+func (e *echoExecutor) Start(ctx context.Context, args ...string) (Process, error) {
+	e.mu.Lock()
+	e.starts++
+	e.mu.Unlock()
+
+	p := &echoProcess{done: make(chan struct{})}
+	p.stdinR, p.stdinW = io.Pipe()
+	p.stdoutR, p.stdoutW = io.Pipe()
+	go p.serve(ctx)
+	return p, nil
+}
+
+func (e *echoExecutor) started() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.starts
+}
+
+type echoProcess struct {
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+	done    chan struct{}
+}
+
+func (p *echoProcess) serve(ctx context.Context) {
+	defer close(p.done)
+	defer p.stdoutW.Close()
+	defer p.stdinR.Close()
+	go func() {
+		<-ctx.Done()
+		p.stdinR.Close()
+	}()
+
+	dec := json.NewDecoder(p.stdinR)
+	for {
+		var req struct {
+			Method string `json:"method"`
+		}
+		var raw json.RawMessage
+		if dec.Decode(&raw) != nil || json.Unmarshal(raw, &req) != nil {
+			return
+		}
+		switch req.Method {
+		case "die":
+			return
+		case "hang":
+			<-ctx.Done()
+			return
+		}
+		out, _ := json.Marshal(map[string]json.RawMessage{"echo": raw})
+		if _, err := p.stdoutW.Write(append(out, '\n')); err != nil {
+			return
+		}
+		switch req.Method {
+		case "bye":
+			return
+		case "deaf":
+			p.stdinR.Close()
+			<-ctx.Done()
+			return
+		}
+	}
+}
+
+func (p *echoProcess) Stdin() io.WriteCloser { return p.stdinW }
+func (p *echoProcess) Stdout() io.Reader     { return p.stdoutR }
+func (p *echoProcess) Wait() error {
+	<-p.done
+	return nil
+}
+
+// echo sends a request for the given method through k's chat session, and
+// checks that it's echoed back
+func echo(ctx context.Context, k *Keybase, method string) error {
+	req := fmt.Sprintf(`{"method":%q}`, method)
+	out, err := k.api(ctx, "chat", method, []byte(req))
+	if err != nil {
+		return err
+	}
+	if want := fmt.Sprintf(`{"echo":%s}`, req); string(out) != want {
+		return fmt.Errorf("got %s, want %s", out, want)
+	}
+	return nil
+}
+
+func TestSessionRestart(t *testing.T) {
+	e := &echoExecutor{}
+	k := &Keybase{Executor: e, Sessions: true}
+	defer k.Close()
+
+	if err := echo(context.Background(), k, "list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(context.Background(), k, "read"); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.started(); n != 1 {
+		t.Errorf("%d sessions started, want 1", n)
+	}
+
+	// A request that kills its session without an answer is sent once more
+	// on a new session, and a session that dies is restarted by the next
+	// request
+	if err := echo(context.Background(), k, "die"); !IsTransient(err) {
+		t.Fatalf("got %v, want a transient error", err)
+	}
+	if n := e.started(); n != 2 {
+		t.Errorf("%d sessions started, want 2", n)
+	}
+	if err := echo(context.Background(), k, "read"); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.started(); n != 3 {
+		t.Errorf("%d sessions started, want 3", n)
+	}
+}
+
+func TestSessionDiesWhileIdle(t *testing.T) {
+	for _, method := range []string{"bye", "deaf"} {
+		e := &echoExecutor{}
+		k := &Keybase{Executor: e, Sessions: true}
+
+		// A session that dies between two requests is restarted, and the
+		// second request succeeds
+		if err := echo(context.Background(), k, method); err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if err := echo(context.Background(), k, "read"); err != nil {
+			t.Errorf("%s: %v", method, err)
+		}
+		if n := e.started(); n != 2 {
+			t.Errorf("%s: %d sessions started, want 2", method, n)
+		}
+		k.Close()
+	}
+}
+
+func TestSessionCancel(t *testing.T) {
+	e := &echoExecutor{}
+	k := &Keybase{Executor: e, Sessions: true}
+	defer k.Close()
+
+	// Cancelling a request kills the session, so that its response can't be
+	// mistaken for the response to the next request
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := echo(ctx, k, "hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := echo(context.Background(), k, "read"); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.started(); n != 2 {
+		t.Errorf("%d sessions started, want 2", n)
+	}
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	e := &echoExecutor{}
+	k := &Keybase{Executor: e, Sessions: true}
+	defer k.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- echo(context.Background(), k, fmt.Sprintf("method-%d-%s", i, strings.Repeat("x", i*50)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := e.started(); n != 1 {
+		t.Errorf("%d sessions started, want 1", n)
+	}
+}
+
+func TestSessionWithoutStdin(t *testing.T) {
+	// fakeExecutor's Processes have a stdin, so hide it
+	e := &fakeExecutor{outputs: map[string]string{"chat api": `{"result":{}}`}}
+	k := &Keybase{Executor: noStdinExecutor{e}, Sessions: true}
+
+	for i := 0; i < 2; i++ {
+		if _, err := k.api(context.Background(), "chat", "list", []byte(`{"method":"list"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The session is only tried once, and then each request runs its own
+	// command
+	if calls, _ := e.history(); len(calls) != 3 || commandName(calls[0]) != "chat api" || len(calls[0]) != 2 {
+		t.Errorf("got %q, want a session and then one command per request", calls)
+	}
+}
+
+// noStdinExecutor starts Processes that don't have a Stdin method
+type noStdinExecutor struct {
+	*fakeExecutor
+}
+
+func (e noStdinExecutor) Start(ctx context.Context, args ...string) (Process, error) {
+	p, err := e.fakeExecutor.Start(ctx, args...)
+	return struct{ Process }{p}, err
+}
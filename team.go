@@ -1,26 +1,39 @@
 package keybase
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 )
 
 // teamAPIOut sends JSON requests to the team API and returns its response.
-func teamAPIOut(k *Keybase, t TeamAPI) (TeamAPI, error) {
+func teamAPIOut(ctx context.Context, k *Keybase, t TeamAPI) (TeamAPI, error) {
+	if err := k.checkSupported("team", t.Method); err != nil {
+		return TeamAPI{}, err
+	}
+	var r TeamAPI
+	err := k.retry(ctx, "team", t.Method, func(ctx context.Context) (err error) {
+		r, err = teamAPICall(ctx, k, t)
+		return err
+	})
+	return r, err
+}
+
+// teamAPICall sends a single JSON request to the team API
+func teamAPICall(ctx context.Context, k *Keybase, t TeamAPI) (TeamAPI, error) {
 	jsonBytes, _ := json.Marshal(t)
 
-	cmdOut, err := k.Exec("team", "api", "-m", string(jsonBytes))
+	cmdOut, err := k.api(ctx, "team", t.Method, jsonBytes)
 	if err != nil {
-		return TeamAPI{}, err
+		return TeamAPI{}, withMethod(err, t.Method)
 	}
 
 	var r TeamAPI
 	if err := json.Unmarshal(cmdOut, &r); err != nil {
-		return TeamAPI{}, err
+		return TeamAPI{}, decodeError(t.Method, err)
 	}
 	if r.Error != nil {
-		return TeamAPI{}, errors.New(r.Error.Message)
+		return TeamAPI{}, newResponseError(t.Method, *r.Error)
 	}
 
 	return r, nil
@@ -28,162 +41,212 @@ func teamAPIOut(k *Keybase, t TeamAPI) (TeamAPI, error) {
 
 // AddUser adds a member to a team by username
 func (t Team) AddUser(user, role string) (TeamAPI, error) {
+	return t.AddUserContext(context.Background(), user, role)
+}
+
+// AddUserContext is like AddUser, but aborts the request when ctx is done
+func (t Team) AddUserContext(ctx context.Context, user, role string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "add-members"
 	m.Params.Options.Team = t.Name
-	m.Params.Options.Usernames = []usernames{
+	m.Params.Options.Usernames = []TeamUsername{
 		{
 			Username: user,
 			Role:     role,
 		},
 	}
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	if err == nil && r.Error == nil {
-		r, err = t.MemberList()
+		r, err = t.MemberListContext(ctx)
 	}
 	return r, err
 }
 
 // RemoveUser removes a member from a team
 func (t Team) RemoveUser(user string) (TeamAPI, error) {
+	return t.RemoveUserContext(context.Background(), user)
+}
+
+// RemoveUserContext is like RemoveUser, but aborts the request when ctx is done
+func (t Team) RemoveUserContext(ctx context.Context, user string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "remove-member"
 	m.Params.Options.Team = t.Name
 	m.Params.Options.Username = user
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	return r, err
 }
 
 // AddReaders adds members to a team by username, and sets their roles to Reader
 func (t Team) AddReaders(users ...string) (TeamAPI, error) {
+	return t.AddReadersContext(context.Background(), users...)
+}
+
+// AddReadersContext is like AddReaders, but aborts the request when ctx is done
+func (t Team) AddReadersContext(ctx context.Context, users ...string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "add-members"
 	m.Params.Options.Team = t.Name
-	addUsers := []usernames{}
+	addUsers := []TeamUsername{}
 	for _, u := range users {
-		addUsers = append(addUsers, usernames{Username: u, Role: "reader"})
+		addUsers = append(addUsers, TeamUsername{Username: u, Role: "reader"})
 	}
 	m.Params.Options.Usernames = addUsers
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	if err == nil && r.Error == nil {
-		r, err = t.MemberList()
+		r, err = t.MemberListContext(ctx)
 	}
 	return r, err
 }
 
 // AddWriters adds members to a team by username, and sets their roles to Writer
 func (t Team) AddWriters(users ...string) (TeamAPI, error) {
+	return t.AddWritersContext(context.Background(), users...)
+}
+
+// AddWritersContext is like AddWriters, but aborts the request when ctx is done
+func (t Team) AddWritersContext(ctx context.Context, users ...string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "add-members"
 	m.Params.Options.Team = t.Name
-	addUsers := []usernames{}
+	addUsers := []TeamUsername{}
 	for _, u := range users {
-		addUsers = append(addUsers, usernames{Username: u, Role: "writer"})
+		addUsers = append(addUsers, TeamUsername{Username: u, Role: "writer"})
 	}
 	m.Params.Options.Usernames = addUsers
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	if err == nil && r.Error == nil {
-		r, err = t.MemberList()
+		r, err = t.MemberListContext(ctx)
 	}
 	return r, err
 }
 
 // AddAdmins adds members to a team by username, and sets their roles to Writer
 func (t Team) AddAdmins(users ...string) (TeamAPI, error) {
+	return t.AddAdminsContext(context.Background(), users...)
+}
+
+// AddAdminsContext is like AddAdmins, but aborts the request when ctx is done
+func (t Team) AddAdminsContext(ctx context.Context, users ...string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "add-members"
 	m.Params.Options.Team = t.Name
-	addUsers := []usernames{}
+	addUsers := []TeamUsername{}
 	for _, u := range users {
-		addUsers = append(addUsers, usernames{Username: u, Role: "admin"})
+		addUsers = append(addUsers, TeamUsername{Username: u, Role: "admin"})
 	}
 	m.Params.Options.Usernames = addUsers
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	if err == nil && r.Error == nil {
-		r, err = t.MemberList()
+		r, err = t.MemberListContext(ctx)
 	}
 	return r, err
 }
 
 // AddOwners adds members to a team by username, and sets their roles to Writer
 func (t Team) AddOwners(users ...string) (TeamAPI, error) {
+	return t.AddOwnersContext(context.Background(), users...)
+}
+
+// AddOwnersContext is like AddOwners, but aborts the request when ctx is done
+func (t Team) AddOwnersContext(ctx context.Context, users ...string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "add-members"
 	m.Params.Options.Team = t.Name
-	addUsers := []usernames{}
+	addUsers := []TeamUsername{}
 	for _, u := range users {
-		addUsers = append(addUsers, usernames{Username: u, Role: "owner"})
+		addUsers = append(addUsers, TeamUsername{Username: u, Role: "owner"})
 	}
 	m.Params.Options.Usernames = addUsers
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	if err == nil && r.Error == nil {
-		r, err = t.MemberList()
+		r, err = t.MemberListContext(ctx)
 	}
 	return r, err
 }
 
 // MemberList returns a list of a team's members
 func (t Team) MemberList() (TeamAPI, error) {
+	return t.MemberListContext(context.Background())
+}
+
+// MemberListContext is like MemberList, but aborts the request when ctx is done
+func (t Team) MemberListContext(ctx context.Context) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "list-team-memberships"
 	m.Params.Options.Team = t.Name
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	return r, err
 }
 
 // CreateSubteam creates a subteam
 func (t Team) CreateSubteam(name string) (TeamAPI, error) {
+	return t.CreateSubteamContext(context.Background(), name)
+}
+
+// CreateSubteamContext is like CreateSubteam, but aborts the request when ctx is done
+func (t Team) CreateSubteamContext(ctx context.Context, name string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "create-team"
 	m.Params.Options.Team = fmt.Sprintf("%s.%s", t.Name, name)
 
-	r, err := teamAPIOut(t.keybase, m)
+	r, err := teamAPIOut(ctx, t.keybase, m)
 	return r, err
 }
 
 // CreateTeam creates a new team
 func (k *Keybase) CreateTeam(name string) (TeamAPI, error) {
+	return k.CreateTeamContext(context.Background(), name)
+}
+
+// CreateTeamContext is like CreateTeam, but aborts the request when ctx is done
+func (k *Keybase) CreateTeamContext(ctx context.Context, name string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "create-team"
 	m.Params.Options.Team = name
 
-	r, err := teamAPIOut(k, m)
+	r, err := teamAPIOut(ctx, k, m)
 	return r, err
 }
 
 // ListUserMemberships returns information about a given user's team memberships
 func (k *Keybase) ListUserMemberships(user string) (TeamAPI, error) {
+	return k.ListUserMembershipsContext(context.Background(), user)
+}
+
+// ListUserMembershipsContext is like ListUserMemberships, but aborts the request when ctx is done
+func (k *Keybase) ListUserMembershipsContext(ctx context.Context, user string) (TeamAPI, error) {
 	m := TeamAPI{
-		Params: &tParams{},
+		Params: &TeamParams{},
 	}
 	m.Method = "list-user-memberships"
 	m.Params.Options.Username = user
 
-	r, err := teamAPIOut(k, m)
+	r, err := teamAPIOut(ctx, k, m)
 	return r, err
 }
@@ -0,0 +1,112 @@
+package keybase
+
+import "time"
+
+// The APIs report timestamps as seconds or milliseconds since the Unix epoch,
+// depending on the field. The accessors below convert them to time.Time, and
+// return the zero Time for timestamps that weren't set.
+
+// fromUnix converts a timestamp in seconds to a time.Time
+func fromUnix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// fromUnixMs converts a timestamp in milliseconds to a time.Time
+func fromUnixMs(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+// Time returns when m was sent
+func (m Message) Time() time.Time {
+	if m.SentAtMs != 0 {
+		return fromUnixMs(m.SentAtMs)
+	}
+	return fromUnix(int64(m.SentAt))
+}
+
+// Age returns how long ago m was sent
+func (m Message) Age() time.Duration {
+	t := m.Time()
+	if t.IsZero() {
+		return 0
+	}
+	return time.Since(t)
+}
+
+// ExplodesAt returns when an exploding message is deleted, or the zero Time if
+// m isn't an exploding message
+func (m Message) ExplodesAt() time.Time {
+	if !m.IsEphemeral {
+		return time.Time{}
+	}
+	return fromUnixMs(m.Etime)
+}
+
+// ActiveTime returns when the last message was sent to c
+func (c Conversation) ActiveTime() time.Time {
+	if c.ActiveAtMs != 0 {
+		return fromUnixMs(c.ActiveAtMs)
+	}
+	return fromUnix(int64(c.ActiveAt))
+}
+
+// Timestamp returns when the payment was made
+func (s PaymentSummary) Timestamp() time.Time {
+	return fromUnixMs(s.Time)
+}
+
+// Timestamp returns when the transaction was made
+func (r WalletResult) Timestamp() time.Time {
+	return fromUnixMs(r.Time)
+}
+
+// Created returns when the commit was made
+func (c Commit) Created() time.Time {
+	return fromUnix(int64(c.Ctime))
+}
+
+// Created returns when the account was created
+func (b UserBasics) Created() time.Time {
+	return fromUnix(int64(b.Ctime))
+}
+
+// Modified returns when the account was last modified
+func (b UserBasics) Modified() time.Time {
+	return fromUnix(int64(b.Mtime))
+}
+
+// Modified returns when the profile was last modified
+func (p UserProfile) Modified() time.Time {
+	return fromUnix(int64(p.Mtime))
+}
+
+// Created returns when the device was added
+func (d UserDevice) Created() time.Time {
+	return fromUnix(int64(d.Ctime))
+}
+
+// Modified returns when the device was last modified
+func (d UserDevice) Modified() time.Time {
+	return fromUnix(int64(d.Mtime))
+}
+
+// Created returns when the device was added
+func (d Device) Created() time.Time {
+	return fromUnixMs(d.CTime)
+}
+
+// Modified returns when the device was last modified
+func (d Device) Modified() time.Time {
+	return fromUnixMs(d.MTime)
+}
+
+// LastUsed returns when the device was last used
+func (d Device) LastUsed() time.Time {
+	return fromUnixMs(d.LastUsedTime)
+}
@@ -0,0 +1,57 @@
+package keybase_test
+
+import (
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+func TestTimestamps(t *testing.T) {
+	srv := keybasetest.NewServer("bot")
+	k := srv.Keybase(t)
+	chat := k.NewChat(keybase.Channel{Name: "alice,bot"})
+
+	before := time.Now().Truncate(time.Millisecond)
+	if _, err := chat.SendEphemeral(time.Hour, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	r, err := chat.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := r.ReadResult().Messages
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages", len(msgs))
+	}
+	m := msgs[0]
+	if sent := m.Time(); sent.Before(before) || sent.After(after) {
+		t.Errorf("sent at %s, want between %s and %s", sent, before, after)
+	}
+	if age := m.Age(); age < 0 || age > time.Since(before) {
+		t.Errorf("got age %s", age)
+	}
+	if d := m.ExplodesAt().Sub(m.Time()); d != time.Hour {
+		t.Errorf("explodes %s after being sent, want 1h", d)
+	}
+
+	list, err := k.ChatList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active := list.ListResult().Conversations[0].ActiveTime(); active.Before(before) || active.After(after) {
+		t.Errorf("active at %s", active)
+	}
+
+	// Zero timestamps are converted to the zero Time
+	var empty keybase.Message
+	if !empty.Time().IsZero() || !empty.ExplodesAt().IsZero() || empty.Age() != 0 {
+		t.Error("zero message has a timestamp")
+	}
+	if got := (keybase.PaymentSummary{Time: 1500}).Timestamp(); !got.Equal(time.Unix(1, 5e8)) {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,655 @@
+package keybase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnexpectedRequest is returned by a Replayer for requests that don't match
+// the next request in its transcript
+var ErrUnexpectedRequest = errors.New("keybase: unexpected request")
+
+// Exchange is a single request to keybase and its response, as written to a
+// transcript by a Recorder and served back by a Replayer. Transcripts are
+// streams of JSON-encoded Exchanges, one per line.
+//
+// Requests to the JSON APIs are recorded with a Command of `<api> api`,
+// whether they were sent with -m or through a session, so a transcript can be
+// replayed with or without sessions. Each line of output from other long-running
+// commands, such as `chat api-listen`, is recorded as its own Exchange, with
+// the Process that produced it.
+type Exchange struct {
+	Command  []string        `json:"command"`
+	Process  int             `json:"process,omitempty"`  // Long-running command that produced the response, or 0
+	Request  json.RawMessage `json:"request,omitempty"`  // JSON API request
+	Response json.RawMessage `json:"response,omitempty"` // Output, if it was valid JSON
+	Output   string          `json:"output,omitempty"`   // Output, if it wasn't valid JSON
+	Error    string          `json:"error,omitempty"`
+	Stderr   string          `json:"stderr,omitempty"`
+	ExitCode int             `json:"exitCode,omitempty"`
+	Exit     bool            `json:"exit,omitempty"` // Set if Process exited on its own
+}
+
+// key returns the command that e is matched against during replay
+func (e Exchange) key() string {
+	return strings.Join(e.Command, " ")
+}
+
+// setOutput records the output of a command
+func (e *Exchange) setOutput(out []byte) {
+	trimmed := bytes.TrimSpace(out)
+	switch {
+	case len(trimmed) > 0 && json.Valid(trimmed):
+		e.Response = append(json.RawMessage(nil), trimmed...)
+	case len(out) > 0:
+		e.Output = string(out)
+	}
+}
+
+// setError records the error returned by a command
+func (e *Exchange) setError(err error) {
+	if err == nil {
+		return
+	}
+	e.Error = err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.Stderr = string(exitErr.Stderr)
+		e.ExitCode = exitErr.ExitCode()
+	}
+}
+
+// output returns the recorded output of the command
+func (e Exchange) output() []byte {
+	if len(e.Response) > 0 {
+		return append(append([]byte(nil), e.Response...), '\n')
+	}
+	if e.Output != "" {
+		return []byte(e.Output)
+	}
+	return nil
+}
+
+// err returns the recorded error of the command
+func (e Exchange) err() error {
+	if e.Error == "" {
+		return nil
+	}
+	return &recordedError{msg: e.Error, stderr: e.Stderr, exitCode: e.ExitCode}
+}
+
+// recordedError is an error replayed from a transcript. newExecError treats it
+// like the *exec.ExitError it was recorded from.
+type recordedError struct {
+	msg      string
+	stderr   string
+	exitCode int
+}
+
+func (e *recordedError) Error() string {
+	return e.msg
+}
+
+// splitAPICommand splits `<api> api -m <request>` into its API and request
+func splitAPICommand(args []string) (api string, req []byte, ok bool) {
+	if len(args) == 4 && args[1] == "api" && args[2] == "-m" {
+		return args[0], []byte(args[3]), true
+	}
+	return "", nil, false
+}
+
+// isSessionCommand reports whether args starts a JSON API session
+func isSessionCommand(args []string) bool {
+	return len(args) == 2 && args[1] == "api"
+}
+
+// transcript is the destination of a Recorder, shared by every Recorder that
+// writes to it
+type transcript struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	procs int
+	err   error
+}
+
+// write appends e to the transcript. Once a write has failed, nothing more
+// is written.
+func (t *transcript) write(e Exchange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(e)
+	}
+}
+
+// nextProcess returns a new process number
+func (t *transcript) nextProcess() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.procs++
+	return t.procs
+}
+
+// Recorder writes every command a Keybase runs, along with the command's
+// output, to a transcript. Transcripts can be served back by a Replayer.
+// Recorders are attached to a Keybase with WithRecorder, and the commands are
+// still run by the Keybase's own Executor.
+//
+// Secrets are redacted from the transcript according to the Keybase's
+// Redaction policy, so that transcripts can be committed. Redacted values in
+// a request match any value when the transcript is replayed.
+type Recorder struct {
+	t *transcript
+}
+
+// NewRecorder returns a Recorder that writes its transcript to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{t: &transcript{enc: json.NewEncoder(w)}}
+}
+
+// Err returns the first error encountered while writing the transcript
+func (r *Recorder) Err() error {
+	r.t.mu.Lock()
+	defer r.t.mu.Unlock()
+	return r.t.err
+}
+
+// recordingExecutor is the Executor of a Keybase with a Recorder. It runs
+// commands with the Keybase's Executor, and records them with the secrets
+// selected by the Keybase's Redaction policy removed.
+type recordingExecutor struct {
+	Executor
+	k *Keybase
+	t *transcript
+}
+
+// Output implements Executor
+func (r *recordingExecutor) Output(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := r.Executor.Output(ctx, args...)
+
+	e := Exchange{Command: r.k.redactCommand(args)}
+	api := ""
+	if a, req, ok := splitAPICommand(args); ok {
+		api = a
+		e.Command = []string{api, "api"}
+		e.Request = validJSON(r.k.redactJSON(api, req))
+	}
+	e.setOutput(r.k.redactJSON(api, out))
+	e.setError(err)
+	r.t.write(e)
+	return out, err
+}
+
+// Start implements Executor. Requests written to the stdin of a JSON API
+// session are expected to be written one per call to Write, as Keybase does.
+func (r *recordingExecutor) Start(ctx context.Context, args ...string) (Process, error) {
+	proc, err := r.Executor.Start(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &recordedProcess{
+		Process: proc,
+		ctx:     ctx,
+		k:       r.k,
+		t:       r.t,
+		command: r.k.redactCommand(args),
+		session: isSessionCommand(args),
+	}
+	if p.session {
+		p.api = args[0]
+	} else {
+		p.id = r.t.nextProcess()
+	}
+	pr, pw := io.Pipe()
+	p.stdout = &teeReader{r: proc.Stdout(), w: pw}
+	go p.record(pr)
+	return p, nil
+}
+
+// validJSON returns b as a json.RawMessage if it's valid JSON, so that it can
+// be embedded in an Exchange, and nil otherwise
+func validJSON(b []byte) json.RawMessage {
+	b = bytes.TrimSpace(b)
+	if !json.Valid(b) {
+		return nil
+	}
+	return append(json.RawMessage(nil), b...)
+}
+
+// recordedProcess is a Process started by a Recorder
+type recordedProcess struct {
+	Process
+	ctx     context.Context
+	k       *Keybase
+	t       *transcript
+	command []string
+	session bool
+	api     string // API of the session, if it is one
+	id      int
+	stdout  io.Reader
+
+	mu       sync.Mutex
+	requests []json.RawMessage // Session requests waiting for a response
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func (p *recordedProcess) Stdin() io.WriteCloser {
+	stdin := processStdin(p.Process)
+	if stdin == nil {
+		return nil
+	}
+	return &recordedStdin{WriteCloser: stdin, p: p}
+}
+
+func (p *recordedProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *recordedProcess) Stderr() string {
+	return processStderr(p.Process)
+}
+
+// Wait waits for the process to exit, and records the exit unless it was
+// caused by the process's context being done
+func (p *recordedProcess) Wait() error {
+	p.waitOnce.Do(func() {
+		p.waitErr = p.Process.Wait()
+		if !p.session && p.ctx.Err() == nil {
+			e := Exchange{Command: p.command, Process: p.id, Exit: true}
+			e.setError(p.waitErr)
+			if stderr := p.Stderr(); stderr != "" {
+				e.Stderr = stderr
+			}
+			p.t.write(e)
+		}
+	})
+	return p.waitErr
+}
+
+// record writes every JSON value read from the process's stdout to the
+// transcript
+func (p *recordedProcess) record(r io.Reader) {
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// Keep draining the pipe so that reads from stdout don't block
+			io.Copy(ioutil.Discard, r)
+			return
+		}
+
+		e := Exchange{Command: p.command, Process: p.id, Response: validJSON(p.k.redactJSON(p.api, raw))}
+		if p.session {
+			p.mu.Lock()
+			if len(p.requests) > 0 {
+				e.Request, p.requests = p.requests[0], p.requests[1:]
+			}
+			p.mu.Unlock()
+		}
+		p.t.write(e)
+	}
+}
+
+// recordedStdin queues up the requests written to a recorded session, so that
+// they can be recorded along with their responses
+type recordedStdin struct {
+	io.WriteCloser
+	p *recordedProcess
+}
+
+func (w *recordedStdin) Write(b []byte) (int, error) {
+	if req := validJSON(w.p.k.redactJSON(w.p.api, b)); req != nil {
+		w.p.mu.Lock()
+		w.p.requests = append(w.p.requests, req)
+		w.p.mu.Unlock()
+	}
+	return w.WriteCloser.Write(b)
+}
+
+// teeReader copies everything read from r to w, and closes w once r is
+// exhausted
+type teeReader struct {
+	r io.Reader
+	w *io.PipeWriter
+}
+
+func (t *teeReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	if n > 0 {
+		t.w.Write(b[:n])
+	}
+	if err != nil {
+		t.w.CloseWithError(err)
+	}
+	return n, err
+}
+
+// Replayer is an Executor that serves responses from a transcript written by
+// a Recorder, instead of running keybase. Requests to each command are
+// expected in the same order as they were recorded, but requests to
+// different commands may be interleaved differently. Unexpected requests fail
+// with ErrUnexpectedRequest.
+//
+// Long-running commands such as `chat api-listen` replay their recorded
+// output as soon as they're started, then keep running until their context
+// is done, unless they were recorded exiting on their own.
+type Replayer struct {
+	mu    sync.Mutex
+	calls map[string][]Exchange   // Requests to Output and JSON API sessions, keyed by command
+	procs map[string][][]Exchange // Output of other long-running commands, keyed by command, then by process
+	err   error
+}
+
+// NewReplayer returns a Replayer that serves the transcript read from r
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	rp := &Replayer{
+		calls: make(map[string][]Exchange),
+		procs: make(map[string][][]Exchange),
+	}
+	procs := make(map[int]int) // Index of each process in rp.procs
+	dec := json.NewDecoder(r)
+	for {
+		var e Exchange
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("keybase: reading transcript: %w", err)
+		}
+
+		key := e.key()
+		if e.Process == 0 {
+			rp.calls[key] = append(rp.calls[key], e)
+			continue
+		}
+		i, ok := procs[e.Process]
+		if !ok {
+			i = len(rp.procs[key])
+			procs[e.Process] = i
+			rp.procs[key] = append(rp.procs[key], nil)
+		}
+		rp.procs[key][i] = append(rp.procs[key][i], e)
+	}
+	return rp, nil
+}
+
+// Err returns the first unexpected request the Replayer received, wrapped in
+// ErrUnexpectedRequest
+func (r *Replayer) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Unused returns the Exchanges in the transcript that haven't been replayed
+func (r *Replayer) Unused() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Exchange
+	for _, calls := range r.calls {
+		unused = append(unused, calls...)
+	}
+	for _, procs := range r.procs {
+		for _, proc := range procs {
+			unused = append(unused, proc...)
+		}
+	}
+	return unused
+}
+
+// next returns the next recorded Exchange for the given command, which must
+// match req. args holds the arguments of commands that aren't sent to a JSON
+// API, which are matched against recorded commands with redacted arguments.
+func (r *Replayer) next(key string, args []string, req []byte) (Exchange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := r.calls[key]
+	if len(calls) == 0 && args != nil {
+		key, calls = r.redactedCalls(key, args)
+	}
+	if len(calls) == 0 {
+		return Exchange{}, r.fail(fmt.Errorf("%w: %s", ErrUnexpectedRequest, describeRequest(key, req)))
+	}
+	if !sameJSON(calls[0].Request, req) {
+		return Exchange{}, r.fail(fmt.Errorf("%w: %s, expected %s", ErrUnexpectedRequest, describeRequest(key, req), describeRequest(key, calls[0].Request)))
+	}
+	r.calls[key] = calls[1:]
+	return calls[0], nil
+}
+
+// redactedCalls returns the recorded calls to a command that had some of its
+// arguments redacted, and that match args otherwise. r.mu must be held.
+func (r *Replayer) redactedCalls(key string, args []string) (string, []Exchange) {
+	for k, calls := range r.calls {
+		if len(calls) > 0 && matchArgs(calls[0].Command, args) {
+			return k, calls
+		}
+	}
+	return key, nil
+}
+
+// matchArgs reports whether a recorded command matches args. Redacted
+// arguments match any value.
+func matchArgs(recorded, args []string) bool {
+	if len(recorded) != len(args) {
+		return false
+	}
+	for i := range recorded {
+		if recorded[i] != args[i] && recorded[i] != redacted {
+			return false
+		}
+	}
+	return true
+}
+
+// fail remembers err if it's the first error, and returns it. r.mu must be
+// held.
+func (r *Replayer) fail(err error) error {
+	if r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// describeRequest formats a request for error messages
+func describeRequest(key string, req []byte) string {
+	if len(req) == 0 {
+		return fmt.Sprintf("%q", key)
+	}
+	return fmt.Sprintf("%q with %s", key, bytes.TrimSpace(req))
+}
+
+// sameJSON reports whether the recorded request a and the request b hold the
+// same JSON value. Redacted values in a match any value.
+func sameJSON(a, b []byte) bool {
+	a, b = bytes.TrimSpace(a), bytes.TrimSpace(b)
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return matchJSON(va, vb)
+}
+
+// matchJSON reports whether the decoded JSON values a and b are equal,
+// treating redacted values in a as wildcards
+func matchJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		return a == redacted || a == b
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			if other, ok := b[key]; !ok || !matchJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !matchJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Output implements Executor
+func (r *Replayer) Output(ctx context.Context, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key, cmd, req := strings.Join(args, " "), args, []byte(nil)
+	if api, body, ok := splitAPICommand(args); ok {
+		key, cmd, req = api+" api", nil, body
+	}
+	e, err := r.next(key, cmd, req)
+	if err != nil {
+		return nil, err
+	}
+	return e.output(), e.err()
+}
+
+// Start implements Executor
+func (r *Replayer) Start(ctx context.Context, args ...string) (Process, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := newReplayProcess(ctx)
+	key := strings.Join(args, " ")
+	if isSessionCommand(args) {
+		go r.serveSession(p, key)
+		return p, nil
+	}
+
+	r.mu.Lock()
+	var recorded []Exchange
+	if procs := r.procs[key]; len(procs) > 0 {
+		recorded, r.procs[key] = procs[0], procs[1:]
+	}
+	r.mu.Unlock()
+	go p.replay(recorded)
+	return p, nil
+}
+
+// serveSession answers the requests written to a replayed JSON API session.
+// Unexpected requests are answered with an API error.
+func (r *Replayer) serveSession(p *replayProcess, key string) {
+	dec := json.NewDecoder(p.stdinR)
+	for {
+		var req json.RawMessage
+		if err := dec.Decode(&req); err != nil {
+			p.exit(nil)
+			return
+		}
+		out := []byte(nil)
+		if e, err := r.next(key, nil, req); err != nil {
+			out, _ = json.Marshal(map[string]interface{}{
+				"error": Error{Message: err.Error()},
+			})
+			out = append(out, '\n')
+		} else {
+			out = e.output()
+		}
+		if _, err := p.stdoutW.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+// replayProcess is a Process started by a Replayer
+type replayProcess struct {
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+
+	mu     sync.Mutex
+	stderr string // Recorded stderr, set when the process exits
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// newReplayProcess returns a replayProcess that is killed when ctx is done
+func newReplayProcess(ctx context.Context) *replayProcess {
+	p := &replayProcess{done: make(chan struct{})}
+	p.stdinR, p.stdinW = io.Pipe()
+	p.stdoutR, p.stdoutW = io.Pipe()
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.exit(ctx.Err())
+		case <-p.done:
+		}
+	}()
+	return p
+}
+
+// replay writes the recorded output of a long-running command to p's stdout,
+// then exits if the command was recorded exiting
+func (p *replayProcess) replay(recorded []Exchange) {
+	for _, e := range recorded {
+		if e.Exit {
+			continue
+		}
+		if _, err := p.stdoutW.Write(e.output()); err != nil {
+			return
+		}
+	}
+	for _, e := range recorded {
+		if e.Exit {
+			p.mu.Lock()
+			p.stderr = e.Stderr
+			p.mu.Unlock()
+			p.exit(e.err())
+		}
+	}
+}
+
+// exit ends the process with the given error
+func (p *replayProcess) exit(err error) {
+	p.once.Do(func() {
+		p.err = err
+		p.stdoutW.Close()
+		p.stdinR.Close()
+		close(p.done)
+	})
+}
+
+func (p *replayProcess) Stdin() io.WriteCloser { return p.stdinW }
+func (p *replayProcess) Stdout() io.Reader     { return p.stdoutR }
+
+func (p *replayProcess) Stderr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stderr
+}
+
+func (p *replayProcess) Wait() error {
+	<-p.done
+	return p.err
+}
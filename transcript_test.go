@@ -0,0 +1,139 @@
+package keybase_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"samhofi.us/x/keybase"
+	"samhofi.us/x/keybase/keybasetest"
+)
+
+// chatSession sends a message, reads it back, and waits for one incoming
+// message through Run
+func chatSession(t *testing.T, k *keybase.Keybase, inject func()) {
+	t.Helper()
+	chat := k.NewChat(keybase.Channel{Name: "alice,bot"})
+	if _, err := chat.Send("hello"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r, err := chat.Read(1)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if n := len(r.Result.Messages); n != 1 || r.Result.Messages[0].Msg.Content.Text.Body != "hello" {
+		t.Fatalf("Read returned %+v", r.Result.Messages)
+	}
+
+	received := make(chan string, 1)
+	go k.Run(func(m keybase.ChatAPI) {
+		if m.Msg != nil {
+			received <- m.Msg.Content.Text.Body
+		}
+	})
+	inject()
+	select {
+	case body := <-received:
+		if body != "ping" {
+			t.Errorf("Run received %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't receive a message")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var transcript bytes.Buffer
+	srv := keybasetest.NewServer("bot")
+	rec := keybase.NewRecorder(&transcript)
+	k := srv.Keybase(t, keybase.WithRecorder(rec))
+	chatSession(t, k, func() {
+		for srv.Listeners() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		srv.InjectMessage(keybase.Channel{Name: "alice,bot"}, "alice", "ping")
+	})
+	k.Close()
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sessions := range []bool{false, true} {
+		r, err := keybase.NewReplayer(bytes.NewReader(transcript.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := []keybase.Option{keybase.WithExecutor(r)}
+		if !sessions {
+			opts = append(opts, keybase.WithoutSessions())
+		}
+		k, err := keybase.New(opts...)
+		if err != nil {
+			t.Fatalf("sessions=%v: New: %v", sessions, err)
+		}
+		if k.Username != "bot" {
+			t.Errorf("sessions=%v: got username %q", sessions, k.Username)
+		}
+		chatSession(t, k, func() {})
+
+		if err := r.Err(); err != nil {
+			t.Errorf("sessions=%v: %v", sessions, err)
+		}
+		if unused := r.Unused(); len(unused) != 0 {
+			t.Errorf("sessions=%v: %d exchanges weren't replayed: %+v", sessions, len(unused), unused)
+		}
+
+		_, err = k.NewChat(keybase.Channel{Name: "alice,bot"}).Send("unexpected")
+		if !errors.Is(err, keybase.ErrUnexpectedRequest) && !sessions {
+			t.Errorf("sessions=%v: got %v, want ErrUnexpectedRequest", sessions, err)
+		}
+		if err == nil || !errors.Is(r.Err(), keybase.ErrUnexpectedRequest) {
+			t.Errorf("sessions=%v: got %v, Err() = %v", sessions, err, r.Err())
+		}
+		k.Close()
+	}
+}
+
+func TestRecordRedaction(t *testing.T) {
+	var transcript bytes.Buffer
+	srv := keybasetest.NewServer("bot")
+	rec := keybase.NewRecorder(&transcript)
+	k := srv.Keybase(t, keybase.WithRecorder(rec), keybase.WithRedaction(keybase.RedactKVValues))
+	if err := k.Oneshot("bot", "secret paper key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.NewKV("").Put("ns", "key", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.ExecContext(context.Background(), "oneshot", "--paperkey", "secret paper key"); err == nil {
+		t.Fatal("oneshot with a flag succeeded")
+	}
+	if s := transcript.String(); strings.Contains(s, "secret") || strings.Contains(s, "hunter2") {
+		t.Fatalf("transcript holds secrets: %s", s)
+	}
+
+	// Redacted values match anything when the transcript is replayed
+	r, err := keybase.NewReplayer(bytes.NewReader(transcript.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err = keybase.New(keybase.WithExecutor(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Oneshot("bot", "another paper key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.NewKV("").Put("ns", "key", "hunter3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.ExecContext(context.Background(), "oneshot", "--paperkey", "another paper key"); errors.Is(err, keybase.ErrUnexpectedRequest) {
+		t.Fatal(err)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,11 @@
 package keybase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,38 +16,56 @@ type RunOptions struct {
 	Local          bool      // Subscribe to local messages
 	HideExploding  bool      // Ignore exploding messages
 	Dev            bool      // Subscribe to dev channel messages
-	Wallet         bool      // Subscribe to wallet events
+	Convs          bool      // Subscribe to new conversations
+	Wallet         bool      // Subscribe to wallet events. Use ChatAPI.PaymentNotification to decode them
 	FilterChannel  Channel   // Only subscribe to messages from specified channel
 	FilterChannels []Channel // Only subscribe to messages from specified channels
+
+	MaxHandlers  int           // Maximum number of handlers running at once (0 = unlimited). Further messages wait until a handler returns
+	Ordered      bool          // Handle messages from the same conversation one at a time, in the order they were received
+	MaxQueued    int           // Maximum number of messages waiting for the previous message from their conversation to be handled, if Ordered is set. Further messages wait until one is handled. Defaults to 100
+	DrainTimeout time.Duration // How long RunContext waits for running handlers when it stops. Defaults to 10 seconds
+
+	PaymentHandler func(PaymentNotification) // Receives wallet events instead of the message handler, if set. Implies Wallet
+
+	ListenerEvents  bool          // Pass lifecycle events of `chat api-listen` to the handler. See ListenerStarted
+	MaxLineSize     int           // Longest message accepted from `chat api-listen`, in bytes. Defaults to 16 MiB
+	RestartDelay    time.Duration // How long to wait before restarting `chat api-listen` after it exits. Doubled while it keeps failing. Defaults to 1 second
+	MaxRestartDelay time.Duration // Upper bound for RestartDelay. Defaults to 1 minute
 }
 
 // ChatAPI holds information about a message received by the `keybase chat api-listen` command
 type ChatAPI struct {
 	Type         string           `json:"type,omitempty"`
 	Source       string           `json:"source,omitempty"`
-	Msg          *msg             `json:"msg,omitempty"`
+	Msg          *Message         `json:"msg,omitempty"`
 	Method       string           `json:"method,omitempty"`
-	Params       *params          `json:"params,omitempty"`
+	Params       *ChatParams      `json:"params,omitempty"`
 	Message      string           `json:"message,omitempty"`
 	ID           int              `json:"id,omitempty"`
-	Ratelimits   []rateLimits     `json:"ratelimits,omitempty"`
-	Notification *notification    `json:"notification,omitempty"`
-	Result       *result          `json:"result,omitempty"`
-	Pagination   *pagination      `json:"pagination,omitempty"`
+	Ratelimits   []RateLimitInfo  `json:"ratelimits,omitempty"`
+	Notification *Notification    `json:"notification,omitempty"`
+	Conv         *Conversation    `json:"conv,omitempty"` // New conversation, received by Run if RunOptions.Convs is set
+	Result       *ChatResult      `json:"result,omitempty"`
+	Pagination   *Pagination      `json:"pagination,omitempty"`
 	ErrorRaw     *json.RawMessage `json:"error,omitempty"` // Raw JSON string containing any errors returned
 	ErrorRead    *Error           `json:"-"`               // Errors returned by any outgoing chat functions such as Read(), Edit(), etc
 	ErrorListen  *string          `json:"-"`               // Errors returned by the api-listen command (used in the Run() function)
-	keybase      Keybase          // Some methods will need this, so I'm passing it but keeping it unexported
+	Listener     *ListenerEvent   `json:"-"`               // Lifecycle event of the api-listen command, if Type is ListenerStarted, ListenerExited or ListenerRestarting
+	keybase      *Keybase         // Some methods will need this, so I'm passing it but keeping it unexported
 }
 
-type sender struct {
+// Sender identifies the user and device that sent a chat message
+type Sender struct {
 	UID        string `json:"uid"`
 	Username   string `json:"username"`
 	DeviceID   string `json:"device_id"`
 	DeviceName string `json:"device_name"`
 }
 
-type addedtoteam struct {
+// AddedToTeam is the content of a system message announcing that a user was
+// added to a team
+type AddedToTeam struct {
 	Team    string   `json:"team"`
 	Adder   string   `json:"adder"`
 	Addee   string   `json:"addee"`
@@ -55,11 +75,14 @@ type addedtoteam struct {
 	Readers []string `json:"readers"`
 }
 
-type bulkaddtoconv struct {
+// BulkAddToConv is the content of a system message announcing that users were
+// added to a conversation
+type BulkAddToConv struct {
 	Usernames []string `json:"usernames"`
 }
 
-type commits struct {
+// Commit is a git commit in a GitPush
+type Commit struct {
 	CommitHash  string `json:"commitHash"`
 	Message     string `json:"message"`
 	AuthorName  string `json:"authorName"`
@@ -67,78 +90,93 @@ type commits struct {
 	Ctime       int    `json:"ctime"`
 }
 
-type refs struct {
-	RefName              string    `json:"refName"`
-	Commits              []commits `json:"commits"`
-	MoreCommitsAvailable bool      `json:"moreCommitsAvailable"`
-	IsDelete             bool      `json:"isDelete"`
+// Ref is a git ref updated by a GitPush
+type Ref struct {
+	RefName              string   `json:"refName"`
+	Commits              []Commit `json:"commits"`
+	MoreCommitsAvailable bool     `json:"moreCommitsAvailable"`
+	IsDelete             bool     `json:"isDelete"`
 }
 
-type gitpush struct {
+// GitPush is the content of a system message announcing a push to a team's
+// git repository
+type GitPush struct {
 	Team             string `json:"team"`
 	Pusher           string `json:"pusher"`
 	RepoName         string `json:"repoName"`
 	RepoID           string `json:"repoID"`
-	Refs             []refs `json:"refs"`
+	Refs             []Ref  `json:"refs"`
 	PushType         int    `json:"pushType"`
 	PreviousRepoName string `json:"previousRepoName"`
 }
 
-type system struct {
+// SystemContent is the content of a system message. Which of its fields is
+// set depends on SystemType.
+type SystemContent struct {
 	SystemType    int           `json:"systemType"`
-	Addedtoteam   addedtoteam   `json:"addedtoteam"`
-	Bulkaddtoconv bulkaddtoconv `json:"bulkaddtoconv"`
-	Gitpush       gitpush       `json:"gitpush"`
+	Addedtoteam   AddedToTeam   `json:"addedtoteam"`
+	Bulkaddtoconv BulkAddToConv `json:"bulkaddtoconv"`
+	Gitpush       GitPush       `json:"gitpush"`
 }
 
-type paymentsResult struct {
+// PaymentResult is the outcome of a payment sent from a chat message
+type PaymentResult struct {
 	ResultTyp int    `json:"resultTyp"`
 	Sent      string `json:"sent"`
 }
 
-type payments struct {
-	Username    string         `json:"username"`
-	PaymentText string         `json:"paymentText"`
-	Result      paymentsResult `json:"result"`
+// Payment is a payment sent from a chat message, e.g. "+5XLM@alice"
+type Payment struct {
+	Username    string        `json:"username"`
+	PaymentText string        `json:"paymentText"`
+	Result      PaymentResult `json:"result"`
 }
 
-type userMentions struct {
+// UserMention is a user @-mentioned in a chat message
+type UserMention struct {
 	Text string `json:"text"`
 	UID  string `json:"uid"`
 }
 
-type teamMentions struct {
+// TeamMention is a team or channel mentioned in a chat message
+type TeamMention struct {
 	Name    string `json:"name"`
 	Channel string `json:"channel"`
 }
 
-type reaction struct {
+// ReactionContent is the content of a reaction. M is the ID of the message
+// reacted to, and B is the reaction.
+type ReactionContent struct {
 	M int    `json:"m"`
 	B string `json:"b"`
 }
 
-type delete struct {
+// DeleteContent is the content of a message that deletes other messages
+type DeleteContent struct {
 	MessageIDs []int `json:"messageIDs"`
 }
 
-type edit struct {
-	MessageID    int            `json:"messageID"`
-	Body         string         `json:"body"`
-	Payments     []payments     `json:"payments"`
-	UserMentions []userMentions `json:"userMentions"`
-	TeamMentions []teamMentions `json:"teamMentions"`
+// EditContent is the content of a message that edits another message
+type EditContent struct {
+	MessageID    int           `json:"messageID"`
+	Body         string        `json:"body"`
+	Payments     []Payment     `json:"payments"`
+	UserMentions []UserMention `json:"userMentions"`
+	TeamMentions []TeamMention `json:"teamMentions"`
 }
 
-type text struct {
-	Body         string         `json:"body"`
-	Payments     []payments     `json:"payments"`
-	ReplyTo      int            `json:"replyTo"`
-	ReplyToUID   string         `json:"replyToUID"`
-	UserMentions []userMentions `json:"userMentions"`
-	TeamMentions []teamMentions `json:"teamMentions"`
+// TextContent is the content of a text message
+type TextContent struct {
+	Body         string        `json:"body"`
+	Payments     []Payment     `json:"payments"`
+	ReplyTo      int           `json:"replyTo"`
+	ReplyToUID   string        `json:"replyToUID"`
+	UserMentions []UserMention `json:"userMentions"`
+	TeamMentions []TeamMention `json:"teamMentions"`
 }
 
-type flip struct {
+// FlipContent is the content of a coin flip message
+type FlipContent struct {
 	Text         string      `json:"text"`
 	GameID       string      `json:"game_id"`
 	FlipConvID   string      `json:"flip_conv_id"`
@@ -146,96 +184,70 @@ type flip struct {
 	TeamMentions interface{} `json:"team_mentions"`
 }
 
-type image struct {
+// ImageMetadata holds the dimensions of an image Asset
+type ImageMetadata struct {
 	Width  int `json:"width"`
 	Height int `json:"height"`
 }
 
-type metadata struct {
-	AssetType int   `json:"assetType"`
-	Image     image `json:"image"`
-}
-
-type preview struct {
-	Filename  string   `json:"filename"`
-	Region    string   `json:"region"`
-	Endpoint  string   `json:"endpoint"`
-	Bucket    string   `json:"bucket"`
-	Path      string   `json:"path"`
-	Size      int      `json:"size"`
-	MimeType  string   `json:"mimeType"`
-	EncHash   string   `json:"encHash"`
-	Key       string   `json:"key"`
-	VerifyKey string   `json:"verifyKey"`
-	Title     string   `json:"title"`
-	Nonce     string   `json:"nonce"`
-	Metadata  metadata `json:"metadata"`
-	Tag       int      `json:"tag"`
-}
-
-type previews struct {
-	Filename  string   `json:"filename"`
-	Region    string   `json:"region"`
-	Endpoint  string   `json:"endpoint"`
-	Bucket    string   `json:"bucket"`
-	Path      string   `json:"path"`
-	Size      int      `json:"size"`
-	MimeType  string   `json:"mimeType"`
-	EncHash   string   `json:"encHash"`
-	Key       string   `json:"key"`
-	VerifyKey string   `json:"verifyKey"`
-	Title     string   `json:"title"`
-	Nonce     string   `json:"nonce"`
-	Metadata  metadata `json:"metadata"`
-	Tag       int      `json:"tag"`
-}
-
-type object struct {
-	Filename  string   `json:"filename"`
-	Region    string   `json:"region"`
-	Endpoint  string   `json:"endpoint"`
-	Bucket    string   `json:"bucket"`
-	Path      string   `json:"path"`
-	Size      int      `json:"size"`
-	MimeType  string   `json:"mimeType"`
-	EncHash   string   `json:"encHash"`
-	Key       string   `json:"key"`
-	VerifyKey string   `json:"verifyKey"`
-	Title     string   `json:"title"`
-	Nonce     string   `json:"nonce"`
-	Metadata  metadata `json:"metadata"`
-	Tag       int      `json:"tag"`
-}
-
-type attachment struct {
-	Object   object     `json:"object"`
-	Preview  preview    `json:"preview"`
-	Previews []previews `json:"previews"`
-	Metadata metadata   `json:"metadata"`
-	Uploaded bool       `json:"uploaded"`
-}
-
-type content struct {
-	Type           string         `json:"type"`
-	Attachment     attachment     `json:"attachment"`
-	Delete         delete         `json:"delete"`
-	Edit           edit           `json:"edit"`
-	Reaction       reaction       `json:"reaction"`
-	System         system         `json:"system"`
-	Text           text           `json:"text"`
-	SendPayment    SendPayment    `json:"send_payment"`
-	RequestPayment RequestPayment `json:"request_payment"`
-	Flip           flip           `json:"flip"`
-}
-
-type msg struct {
+// AssetMetadata describes the file stored in an Asset
+type AssetMetadata struct {
+	AssetType int           `json:"assetType"`
+	Image     ImageMetadata `json:"image"`
+}
+
+// Asset is a file stored by keybase for an attachment: the uploaded file
+// itself, or one of its previews
+type Asset struct {
+	Filename  string        `json:"filename"`
+	Region    string        `json:"region"`
+	Endpoint  string        `json:"endpoint"`
+	Bucket    string        `json:"bucket"`
+	Path      string        `json:"path"`
+	Size      int           `json:"size"`
+	MimeType  string        `json:"mimeType"`
+	EncHash   string        `json:"encHash"`
+	Key       string        `json:"key"`
+	VerifyKey string        `json:"verifyKey"`
+	Title     string        `json:"title"`
+	Nonce     string        `json:"nonce"`
+	Metadata  AssetMetadata `json:"metadata"`
+	Tag       int           `json:"tag"`
+}
+
+// AttachmentContent is the content of an attachment message
+type AttachmentContent struct {
+	Object   Asset         `json:"object"`
+	Preview  Asset         `json:"preview"`
+	Previews []Asset       `json:"previews"`
+	Metadata AssetMetadata `json:"metadata"`
+	Uploaded bool          `json:"uploaded"`
+}
+
+// Content is the content of a chat message. Which of its fields is set
+// depends on Type, e.g. Text is set if Type is "text".
+type Content struct {
+	Type           string            `json:"type"`
+	Attachment     AttachmentContent `json:"attachment"`
+	Delete         DeleteContent     `json:"delete"`
+	Edit           EditContent       `json:"edit"`
+	Reaction       ReactionContent   `json:"reaction"`
+	System         SystemContent     `json:"system"`
+	Text           TextContent       `json:"text"`
+	SendPayment    SendPayment       `json:"send_payment"`
+	RequestPayment RequestPayment    `json:"request_payment"`
+	Flip           FlipContent       `json:"flip"`
+}
+
+// Message is a chat message, as returned by Chat.Read or received by Run
+type Message struct {
 	ID                 int      `json:"id"`
 	ConversationID     string   `json:"conversation_id"`
 	Channel            Channel  `json:"channel"`
-	Sender             sender   `json:"sender"`
+	Sender             Sender   `json:"sender"`
 	SentAt             int      `json:"sent_at"`
 	SentAtMs           int64    `json:"sent_at_ms"`
-	Content            content  `json:"content"`
+	Content            Content  `json:"content"`
 	Unread             bool     `json:"unread"`
 	AtMentionUsernames []string `json:"at_mention_usernames"`
 	IsEphemeral        bool     `json:"is_ephemeral"`
@@ -244,46 +256,49 @@ type msg struct {
 	ChannelMention     string   `json:"channel_mention"`
 }
 
-type summary struct {
-	ID                  string      `json:"id"`
-	TxID                string      `json:"txID"`
-	Time                int64       `json:"time"`
-	StatusSimplified    int         `json:"statusSimplified"`
-	StatusDescription   string      `json:"statusDescription"`
-	StatusDetail        string      `json:"statusDetail"`
-	ShowCancel          bool        `json:"showCancel"`
-	AmountDescription   string      `json:"amountDescription"`
-	Delta               int         `json:"delta"`
-	Worth               string      `json:"worth"`
-	WorthAtSendTime     string      `json:"worthAtSendTime"`
-	IssuerDescription   string      `json:"issuerDescription"`
-	FromType            int         `json:"fromType"`
-	ToType              int         `json:"toType"`
-	AssetCode           string      `json:"assetCode"`
-	FromAccountID       string      `json:"fromAccountID"`
-	FromAccountName     string      `json:"fromAccountName"`
-	FromUsername        string      `json:"fromUsername"`
-	ToAccountID         string      `json:"toAccountID"`
-	ToAccountName       string      `json:"toAccountName"`
-	ToUsername          string      `json:"toUsername"`
-	ToAssertion         string      `json:"toAssertion"`
-	OriginalToAssertion string      `json:"originalToAssertion"`
-	Note                string      `json:"note"`
-	NoteErr             string      `json:"noteErr"`
-	SourceAmountMax     string      `json:"sourceAmountMax"`
-	SourceAmountActual  string      `json:"sourceAmountActual"`
-	SourceAsset         sourceAsset `json:"sourceAsset"`
-	SourceConvRate      string      `json:"sourceConvRate"`
-	IsAdvanced          bool        `json:"isAdvanced"`
-	SummaryAdvanced     string      `json:"summaryAdvanced"`
-	Operations          interface{} `json:"operations"`
-	Unread              bool        `json:"unread"`
-	BatchID             string      `json:"batchID"`
-	FromAirdrop         bool        `json:"fromAirdrop"`
-	IsInflation         bool        `json:"isInflation"`
-}
-
-type details struct {
+// PaymentSummary describes a Stellar payment in a wallet Notification
+type PaymentSummary struct {
+	ID                  string             `json:"id"`
+	TxID                string             `json:"txID"`
+	Time                int64              `json:"time"`
+	StatusSimplified    int                `json:"statusSimplified"`
+	StatusDescription   string             `json:"statusDescription"`
+	StatusDetail        string             `json:"statusDetail"`
+	ShowCancel          bool               `json:"showCancel"`
+	AmountDescription   string             `json:"amountDescription"`
+	Delta               int                `json:"delta"`
+	Worth               string             `json:"worth"`
+	WorthAtSendTime     string             `json:"worthAtSendTime"`
+	IssuerDescription   string             `json:"issuerDescription"`
+	FromType            int                `json:"fromType"`
+	ToType              int                `json:"toType"`
+	AssetCode           string             `json:"assetCode"`
+	FromAccountID       string             `json:"fromAccountID"`
+	FromAccountName     string             `json:"fromAccountName"`
+	FromUsername        string             `json:"fromUsername"`
+	ToAccountID         string             `json:"toAccountID"`
+	ToAccountName       string             `json:"toAccountName"`
+	ToUsername          string             `json:"toUsername"`
+	ToAssertion         string             `json:"toAssertion"`
+	OriginalToAssertion string             `json:"originalToAssertion"`
+	Note                string             `json:"note"`
+	NoteErr             string             `json:"noteErr"`
+	SourceAmountMax     string             `json:"sourceAmountMax"`
+	SourceAmountActual  string             `json:"sourceAmountActual"`
+	SourceAsset         StellarSourceAsset `json:"sourceAsset"`
+	SourceConvRate      string             `json:"sourceConvRate"`
+	IsAdvanced          bool               `json:"isAdvanced"`
+	SummaryAdvanced     string             `json:"summaryAdvanced"`
+	Operations          interface{}        `json:"operations"`
+	Unread              bool               `json:"unread"`
+	BatchID             string             `json:"batchID"`
+	FromAirdrop         bool               `json:"fromAirdrop"`
+	IsInflation         bool               `json:"isInflation"`
+}
+
+// PaymentDetails holds additional information about a Stellar payment in a
+// wallet Notification
+type PaymentDetails struct {
 	PublicNote            string      `json:"publicNote"`
 	PublicNoteType        string      `json:"publicNoteType"`
 	ExternalTxURL         string      `json:"externalTxURL"`
@@ -291,9 +306,10 @@ type details struct {
 	PathIntermediate      interface{} `json:"pathIntermediate"`
 }
 
-type notification struct {
-	Summary summary `json:"summary"`
-	Details details `json:"details"`
+// Notification is a wallet event received by Run, if RunOptions.Wallet is set
+type Notification struct {
+	Summary PaymentSummary `json:"summary"`
+	Details PaymentDetails `json:"details"`
 }
 
 // Channel holds information about a conversation
@@ -305,6 +321,8 @@ type Channel struct {
 	TopicName   string `json:"topic_name,omitempty"`
 }
 
+// BotCommand is a command advertised by a bot. Use NewBotCommand to create
+// one.
 type BotCommand struct {
 	Name                string                         `json:"name"`
 	Description         string                         `json:"description"`
@@ -312,40 +330,47 @@ type BotCommand struct {
 	ExtendedDescription *BotCommandExtendedDescription `json:"extended_description,omitempty"`
 }
 
+// BotCommandExtendedDescription is the longer help text of a BotCommand
 type BotCommandExtendedDescription struct {
 	Title       string `json:"title"`
 	DesktopBody string `json:"desktop_body"`
 	MobileBody  string `json:"mobile_body"`
 }
 
+// BotAdvertisement is a set of BotCommands, and where to advertise them
 type BotAdvertisement struct {
 	Type        string       `json:"type"`                // "public", "teamconvs", "teammembers"
 	TeamName    string       `json:"team_name,omitempty"` // required if Type is not "public"
 	BotCommands []BotCommand `json:"commands"`
 }
 
-type mesg struct {
+// MessageBody is the body of a message sent to the chat API
+type MessageBody struct {
 	Body string `json:"body"`
 }
 
-type duration struct {
+// Duration is a time.Duration that is encoded in JSON as a string, e.g. "1h0m0s"
+type Duration struct {
 	time.Duration
 }
 
-func (d *duration) UnmarshalJSON(b []byte) (err error) {
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
 	d.Duration, err = time.ParseDuration(strings.Trim(string(b), `"`))
 	return
 }
 
-func (d *duration) MarshalJSON() (b []byte, err error) {
+// MarshalJSON implements json.Marshaler
+func (d *Duration) MarshalJSON() (b []byte, err error) {
 	return []byte(fmt.Sprintf(`"%s"`, d.String())), nil
 }
 
-type options struct {
+// ChatOptions holds the options of a chat API request
+type ChatOptions struct {
 	Channel            *Channel           `json:"channel,omitempty"`
 	MessageID          int                `json:"message_id,omitempty"`
-	Message            *mesg              `json:"message,omitempty"`
-	Pagination         *pagination        `json:"pagination,omitempty"`
+	Message            *MessageBody       `json:"message,omitempty"`
+	Pagination         *Pagination        `json:"pagination,omitempty"`
 	Filename           string             `json:"filename,omitempty,omitempty"`
 	Title              string             `json:"title,omitempty,omitempty"`
 	Output             string             `json:"output,omitempty,omitempty"`
@@ -355,8 +380,11 @@ type options struct {
 	ReplyTo            int                `json:"reply_to,omitempty"`
 	GameID             string             `json:"game_id,omitempty"`
 	Alias              string             `json:"alias,omitempty"`
+	Query              string             `json:"query,omitempty"`
+	Username           string             `json:"username,omitempty"`
+	Role               string             `json:"role,omitempty"`
 	BotAdvertisements  []BotAdvertisement `json:"advertisements,omitempty"`
-	ExplodingLifetime  duration           `json:"exploding_lifetime,omitempty"`
+	ExplodingLifetime  Duration           `json:"exploding_lifetime,omitempty"`
 
 	Name        string `json:"name,omitempty"`
 	Public      bool   `json:"public,omitempty"`
@@ -365,11 +393,14 @@ type options struct {
 	TopicName   string `json:"topic_name,omitempty"`
 }
 
-type params struct {
-	Options options `json:"options"`
+// ChatParams holds the parameters of a chat API request
+type ChatParams struct {
+	Options ChatOptions `json:"options"`
 }
 
-type pagination struct {
+// Pagination holds the position of a page of messages, for reading the next
+// or previous page
+type Pagination struct {
 	Next           string `json:"next"`
 	Previous       string `json:"previous"`
 	Num            int    `json:"num"`
@@ -377,7 +408,8 @@ type pagination struct {
 	ForceFirstPage bool   `json:"forceFirstPage,omitempty"`
 }
 
-type participants struct {
+// FlipParticipant is a participant in a coin flip
+type FlipParticipant struct {
 	UID        string `json:"uid"`
 	DeviceID   string `json:"deviceID"`
 	Username   string `json:"username"`
@@ -386,57 +418,68 @@ type participants struct {
 	Reveal     string `json:"reveal"`
 }
 
-type dupreg struct {
+// FlipDupReg identifies a device that registered more than once for a coin
+// flip
+type FlipDupReg struct {
 	User   string `json:"user"`
 	Device string `json:"device"`
 }
 
-type errorInfo struct {
-	Typ    int    `json:"typ"`
-	Dupreg dupreg `json:"dupreg"`
+// FlipErrorInfo describes why a coin flip failed
+type FlipErrorInfo struct {
+	Typ    int        `json:"typ"`
+	Dupreg FlipDupReg `json:"dupreg"`
 }
 
-type resultInfo struct {
+// FlipResultInfo holds the result of a coin flip
+type FlipResultInfo struct {
 	Typ  int  `json:"typ"`
 	Coin bool `json:"coin"`
 }
 
-type flipStatus struct {
-	GameID                  string         `json:"gameID"`
-	Phase                   int            `json:"phase"`
-	ProgressText            string         `json:"progressText"`
-	ResultText              string         `json:"resultText"`
-	CommitmentVisualization string         `json:"commitmentVisualization"`
-	RevealVisualization     string         `json:"revealVisualization"`
-	Participants            []participants `json:"participants"`
-	ResultInfo              *resultInfo    `json:"resultInfo"`
-	ErrorInfo               *errorInfo     `json:"errorInfo"`
-}
-
-type result struct {
-	Messages         []messages     `json:"messages,omitempty"`
-	Pagination       pagination     `json:"pagination"`
-	Message          string         `json:"message"`
-	ID               int            `json:"id"`
-	Ratelimits       []rateLimits   `json:"ratelimits"`
-	Conversations    []conversation `json:"conversations,omitempty"`
-	Offline          bool           `json:"offline,omitempty"`
-	Status           flipStatus     `json:"status,omitempty"`
-	IdentifyFailures interface{}    `json:"identifyFailures,omitempty"`
-}
-
-type messages struct {
-	Msg msg `json:"msg,omitempty"`
-}
-
-type rateLimits struct {
+// FlipStatus is the state of a coin flip, as returned by Chat.LoadFlip
+type FlipStatus struct {
+	GameID                  string            `json:"gameID"`
+	Phase                   int               `json:"phase"`
+	ProgressText            string            `json:"progressText"`
+	ResultText              string            `json:"resultText"`
+	CommitmentVisualization string            `json:"commitmentVisualization"`
+	RevealVisualization     string            `json:"revealVisualization"`
+	Participants            []FlipParticipant `json:"participants"`
+	ResultInfo              *FlipResultInfo   `json:"resultInfo"`
+	ErrorInfo               *FlipErrorInfo    `json:"errorInfo"`
+}
+
+// ChatResult holds the result of a chat API request
+type ChatResult struct {
+	Messages         []MessageEntry  `json:"messages,omitempty"`
+	Pagination       Pagination      `json:"pagination"`
+	Message          string          `json:"message"`
+	ID               int             `json:"id"`
+	OutboxID         string          `json:"outbox_id,omitempty"`
+	Ratelimits       []RateLimitInfo `json:"ratelimits"`
+	Conversations    []Conversation  `json:"conversations,omitempty"`
+	Offline          bool            `json:"offline,omitempty"`
+	Status           FlipStatus      `json:"status,omitempty"`
+	IdentifyFailures interface{}     `json:"identifyFailures,omitempty"`
+}
+
+// MessageEntry is an element of ChatResult.Messages
+type MessageEntry struct {
+	Msg Message `json:"msg,omitempty"`
+}
+
+// RateLimitInfo is the state of a rate-limit tank, as reported in a chat API
+// response. Reset is in seconds from the time of the response. See RateLimit.
+type RateLimitInfo struct {
 	Tank     string `json:"tank,omitempty"`
 	Capacity int    `json:"capacity,omitempty"`
 	Reset    int    `json:"reset,omitempty"`
 	Gas      int    `json:"gas,omitempty"`
 }
 
-type conversation struct {
+// Conversation is a chat conversation, as returned by ChatList
+type Conversation struct {
 	ID           string  `json:"id"`
 	Channel      Channel `json:"channel"`
 	Unread       bool    `json:"unread"`
@@ -445,10 +488,12 @@ type conversation struct {
 	MemberStatus string  `json:"member_status"`
 }
 
+// SendPayment is the content of a message that sent a Stellar payment
 type SendPayment struct {
 	PaymentID string `json:"paymentID"`
 }
 
+// RequestPayment is the content of a message that requested a Stellar payment
 type RequestPayment struct {
 	RequestID string `json:"requestID"`
 	Note      string `json:"note"`
@@ -456,13 +501,14 @@ type RequestPayment struct {
 
 // WalletAPI holds data for sending to API
 type WalletAPI struct {
-	Method string   `json:"method,omitempty"`
-	Params *wParams `json:"params,omitempty"`
-	Result *wResult `json:"result,omitempty"`
-	Error  *Error   `json:"error"`
+	Method string        `json:"method,omitempty"`
+	Params *WalletParams `json:"params,omitempty"`
+	Result *WalletResult `json:"result,omitempty"`
+	Error  *Error        `json:"error"`
 }
 
-type wOptions struct {
+// WalletOptions holds the options of a wallet API request
+type WalletOptions struct {
 	Name      string `json:"name"`
 	Txid      string `json:"txid"`
 	Recipient string `json:"recipient"`
@@ -471,11 +517,13 @@ type wOptions struct {
 	Message   string `json:"message"`
 }
 
-type wParams struct {
-	Options wOptions `json:"options"`
+// WalletParams holds the parameters of a wallet API request
+type WalletParams struct {
+	Options WalletOptions `json:"options"`
 }
 
-type asset struct {
+// StellarAsset is an asset on the Stellar network, e.g. native XLM
+type StellarAsset struct {
 	Type           string `json:"type"`
 	Code           string `json:"code"`
 	Issuer         string `json:"issuer"`
@@ -485,7 +533,8 @@ type asset struct {
 	InfoURL        string `json:"infoUrl"`
 }
 
-type sourceAsset struct {
+// StellarSourceAsset is the asset a path payment was sent from
+type StellarSourceAsset struct {
 	Type           string `json:"type"`
 	Code           string `json:"code"`
 	Issuer         string `json:"issuer"`
@@ -496,166 +545,189 @@ type sourceAsset struct {
 	InfoURLText    string `json:"infoUrlText"`
 }
 
-type balance struct {
-	Asset  asset  `json:"asset"`
-	Amount string `json:"amount"`
-	Limit  string `json:"limit"`
+// Balance is the balance of a Stellar account in one asset
+type Balance struct {
+	Asset  StellarAsset `json:"asset"`
+	Amount string       `json:"amount"`
+	Limit  string       `json:"limit"`
 }
 
-type exchangeRate struct {
+// ExchangeRate is the rate at which XLM is converted to a display currency
+type ExchangeRate struct {
 	Currency string `json:"currency"`
 	Rate     string `json:"rate"`
 }
 
-type wResult struct {
-	AccountID          string       `json:"accountID"`
-	IsPrimary          bool         `json:"isPrimary"`
-	Name               string       `json:"name"`
-	Balance            []balance    `json:"balance"`
-	ExchangeRate       exchangeRate `json:"exchangeRate"`
-	AccountMode        int          `json:"accountMode"`
-	TxID               string       `json:"txID"`
-	Time               int64        `json:"time"`
-	Status             string       `json:"status"`
-	StatusDetail       string       `json:"statusDetail"`
-	Amount             string       `json:"amount"`
-	Asset              asset        `json:"asset"`
-	DisplayAmount      string       `json:"displayAmount"`
-	DisplayCurrency    string       `json:"displayCurrency"`
-	SourceAmountMax    string       `json:"sourceAmountMax"`
-	SourceAmountActual string       `json:"sourceAmountActual"`
-	SourceAsset        sourceAsset  `json:"sourceAsset"`
-	FromStellar        string       `json:"fromStellar"`
-	ToStellar          string       `json:"toStellar"`
-	FromUsername       string       `json:"fromUsername"`
-	ToUsername         string       `json:"toUsername"`
-	Note               string       `json:"note"`
-	NoteErr            string       `json:"noteErr"`
-	Unread             bool         `json:"unread"`
-	Username           string       `json:"username"`
+// WalletResult holds the result of a wallet API request: an account for
+// lookups, or a transaction for sends and TxDetail
+type WalletResult struct {
+	AccountID          string             `json:"accountID"`
+	IsPrimary          bool               `json:"isPrimary"`
+	Name               string             `json:"name"`
+	Balance            []Balance          `json:"balance"`
+	ExchangeRate       ExchangeRate       `json:"exchangeRate"`
+	AccountMode        int                `json:"accountMode"`
+	TxID               string             `json:"txID"`
+	Time               int64              `json:"time"`
+	Status             string             `json:"status"`
+	StatusDetail       string             `json:"statusDetail"`
+	Amount             string             `json:"amount"`
+	Asset              StellarAsset       `json:"asset"`
+	DisplayAmount      string             `json:"displayAmount"`
+	DisplayCurrency    string             `json:"displayCurrency"`
+	SourceAmountMax    string             `json:"sourceAmountMax"`
+	SourceAmountActual string             `json:"sourceAmountActual"`
+	SourceAsset        StellarSourceAsset `json:"sourceAsset"`
+	FromStellar        string             `json:"fromStellar"`
+	ToStellar          string             `json:"toStellar"`
+	FromUsername       string             `json:"fromUsername"`
+	ToUsername         string             `json:"toUsername"`
+	Note               string             `json:"note"`
+	NoteErr            string             `json:"noteErr"`
+	Unread             bool               `json:"unread"`
+	Username           string             `json:"username"`
 }
 
 // TeamAPI holds information sent and received to/from the team api
 type TeamAPI struct {
-	Method string   `json:"method,omitempty"`
-	Params *tParams `json:"params,omitempty"`
-	Result *tResult `json:"result,omitempty"`
-	Error  *Error   `json:"error"`
+	Method string      `json:"method,omitempty"`
+	Params *TeamParams `json:"params,omitempty"`
+	Result *TeamResult `json:"result,omitempty"`
+	Error  *Error      `json:"error"`
 }
 
-type emails struct {
+// TeamEmail is a user to invite to a team by email address
+type TeamEmail struct {
 	Email string `json:"email"`
 	Role  string `json:"role"`
 }
 
-type usernames struct {
+// TeamUsername is a user to add to a team, with the role to give them
+type TeamUsername struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
 }
 
-type user struct {
+// TeamUser identifies a user added to a team
+type TeamUser struct {
 	UID      string `json:"uid"`
 	Username string `json:"username"`
 }
 
-type uv struct {
+// UserVersion identifies a version of a user account, which changes when the
+// account is reset
+type UserVersion struct {
 	UID         string `json:"uid"`
 	EldestSeqno int    `json:"eldestSeqno"`
 }
 
-type member struct {
-	Uv       uv     `json:"uv"`
-	Username string `json:"username"`
-	FullName string `json:"fullName"`
-	NeedsPUK bool   `json:"needsPUK"`
-	Status   int    `json:"status"`
+// Member is a member of a team
+type Member struct {
+	Uv       UserVersion `json:"uv"`
+	Username string      `json:"username"`
+	FullName string      `json:"fullName"`
+	NeedsPUK bool        `json:"needsPUK"`
+	Status   int         `json:"status"`
 }
 
-type members struct {
-	Owners  []member `json:"owners"`
-	Admins  []member `json:"admins"`
-	Writers []member `json:"writers"`
-	Readers []member `json:"readers"`
+// Members holds the members of a team, by role
+type Members struct {
+	Owners  []Member `json:"owners"`
+	Admins  []Member `json:"admins"`
+	Writers []Member `json:"writers"`
+	Readers []Member `json:"readers"`
 }
 
-type annotatedActiveInvites struct {
+// AnnotatedActiveInvites holds a team's pending invites
+type AnnotatedActiveInvites struct {
 }
 
-type settings struct {
+// TeamSettings holds the settings of a team
+type TeamSettings struct {
 	Open   bool `json:"open"`
 	JoinAs int  `json:"joinAs"`
 }
 
-type showcase struct {
+// Showcase holds whether a team is shown on its members' profiles
+type Showcase struct {
 	IsShowcased       bool `json:"is_showcased"`
 	AnyMemberShowcase bool `json:"any_member_showcase"`
 }
 
-type tOptions struct {
-	Team      string      `json:"team"`
-	Emails    []emails    `json:"emails"`
-	Usernames []usernames `json:"usernames"`
-	Username  string      `json:"username"`
+// TeamOptions holds the options of a team API request
+type TeamOptions struct {
+	Team      string         `json:"team"`
+	Emails    []TeamEmail    `json:"emails"`
+	Usernames []TeamUsername `json:"usernames"`
+	Username  string         `json:"username"`
 }
 
-type tParams struct {
-	Options tOptions `json:"options"`
+// TeamParams holds the parameters of a team API request
+type TeamParams struct {
+	Options TeamOptions `json:"options"`
 }
 
+// Error is an error returned by a JSON API
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
 
-type tResult struct {
+// TeamResult holds the result of a team API request
+type TeamResult struct {
 	ChatSent               bool                   `json:"chatSent"`
 	CreatorAdded           bool                   `json:"creatorAdded"`
 	Invited                bool                   `json:"invited"`
-	User                   user                   `json:"user"`
+	User                   TeamUser               `json:"user"`
 	EmailSent              bool                   `json:"emailSent"`
 	ChatSending            bool                   `json:"chatSending"`
-	Members                members                `json:"members"`
+	Members                Members                `json:"members"`
 	KeyGeneration          int                    `json:"keyGeneration"`
-	AnnotatedActiveInvites annotatedActiveInvites `json:"annotatedActiveInvites"`
-	Settings               settings               `json:"settings"`
-	Showcase               showcase               `json:"showcase"`
-	Teams                  []teamInfo             `json:"teams"`
+	AnnotatedActiveInvites AnnotatedActiveInvites `json:"annotatedActiveInvites"`
+	Settings               TeamSettings           `json:"settings"`
+	Showcase               Showcase               `json:"showcase"`
+	Teams                  []TeamInfo             `json:"teams"`
 }
 
-type implicit struct {
+// ImplicitRole is the role a user has in a team by being an admin of one of
+// its ancestors
+type ImplicitRole struct {
 	Role     int    `json:"role"`
 	Ancestor string `json:"ancestor"`
 }
 
-type teamInfo struct {
-	UID                     string   `json:"uid"`
-	TeamID                  string   `json:"team_id"`
-	Username                string   `json:"username"`
-	FullName                string   `json:"full_name"`
-	FqName                  string   `json:"fq_name"`
-	IsImplicitTeam          bool     `json:"is_implicit_team"`
-	ImplicitTeamDisplayName string   `json:"implicit_team_display_name"`
-	IsOpenTeam              bool     `json:"is_open_team"`
-	Role                    int      `json:"role"`
-	NeedsPUK                bool     `json:"needsPUK"`
-	MemberCount             int      `json:"member_count"`
-	MemberEldestSeqno       int      `json:"member_eldest_seqno"`
-	AllowProfilePromote     bool     `json:"allow_profile_promote"`
-	IsMemberShowcased       bool     `json:"is_member_showcased"`
-	Status                  int      `json:"status"`
-	Implicit                implicit `json:"implicit,omitempty"`
+// TeamInfo describes a user's membership of a team, as returned by
+// ListUserMemberships
+type TeamInfo struct {
+	UID                     string       `json:"uid"`
+	TeamID                  string       `json:"team_id"`
+	Username                string       `json:"username"`
+	FullName                string       `json:"full_name"`
+	FqName                  string       `json:"fq_name"`
+	IsImplicitTeam          bool         `json:"is_implicit_team"`
+	ImplicitTeamDisplayName string       `json:"implicit_team_display_name"`
+	IsOpenTeam              bool         `json:"is_open_team"`
+	Role                    int          `json:"role"`
+	NeedsPUK                bool         `json:"needsPUK"`
+	MemberCount             int          `json:"member_count"`
+	MemberEldestSeqno       int          `json:"member_eldest_seqno"`
+	AllowProfilePromote     bool         `json:"allow_profile_promote"`
+	IsMemberShowcased       bool         `json:"is_member_showcased"`
+	Status                  int          `json:"status"`
+	Implicit                ImplicitRole `json:"implicit,omitempty"`
 }
 
 // KVAPI holds information sent and received to/from the kvstore api
 type KVAPI struct {
 	Method  string    `json:"method,omitempty"`
-	Params  *kvParams `json:"params,omitempty"`
-	Result  *kvResult `json:"result,omitempty"`
+	Params  *KVParams `json:"params,omitempty"`
+	Result  *KVResult `json:"result,omitempty"`
 	Error   *Error    `json:"error"`
-	keybase Keybase
+	keybase *Keybase
 }
 
-type kvOptions struct {
+// KVOptions holds the options of a kvstore API request
+type KVOptions struct {
 	Team       string `json:"team,omitempty"`
 	Namespace  string `json:"namespace,omitempty"`
 	EntryKey   string `json:"entryKey,omitempty"`
@@ -663,36 +735,41 @@ type kvOptions struct {
 	EntryValue string `json:"entryValue,omitempty"`
 }
 
-type kvParams struct {
-	Options kvOptions `json:"options,omitempty"`
+// KVParams holds the parameters of a kvstore API request
+type KVParams struct {
+	Options KVOptions `json:"options,omitempty"`
 }
 
-type entryKey struct {
+// KVEntryKey is a key in a kvstore namespace, as returned by KV.Keys
+type KVEntryKey struct {
 	EntryKey string `json:"entryKey"`
 	Revision uint   `json:"revision"`
 }
 
-type kvResult struct {
-	TeamName   string     `json:"teamName"`
-	Namespaces []string   `json:"namespaces"`
-	EntryKeys  []entryKey `json:"entryKeys"`
-	EntryKey   string     `json:"entryKey"`
-	EntryValue string     `json:"entryValue"`
-	Revision   uint       `json:"revision"`
+// KVResult holds the result of a kvstore API request
+type KVResult struct {
+	TeamName   string       `json:"teamName"`
+	Namespaces []string     `json:"namespaces"`
+	EntryKeys  []KVEntryKey `json:"entryKeys"`
+	EntryKey   string       `json:"entryKey"`
+	EntryValue string       `json:"entryValue"`
+	Revision   uint         `json:"revision"`
 }
 
 // UserAPI holds information received from the user/lookup api
 type UserAPI struct {
-	Status uStatus `json:"status"`
-	Them   []them  `json:"them"`
+	Status APIStatus  `json:"status"`
+	Them   []UserInfo `json:"them"`
 }
 
-type uStatus struct {
+// APIStatus is the status of a request to the keybase web API
+type APIStatus struct {
 	Code int    `json:"code"`
 	Name string `json:"name"`
 }
 
-type basics struct {
+// UserBasics holds basic information about a user account
+type UserBasics struct {
 	Ctime                int    `json:"ctime"`
 	EldestSeqno          int    `json:"eldest_seqno"`
 	IDVersion            int    `json:"id_version"`
@@ -707,14 +784,16 @@ type basics struct {
 	UsernameCased        string `json:"username_cased"`
 }
 
-type profile struct {
+// UserProfile holds the profile a user filled in
+type UserProfile struct {
 	Bio      string `json:"bio"`
 	FullName string `json:"full_name"`
 	Location string `json:"location"`
 	Mtime    int    `json:"mtime"`
 }
 
-type proof struct {
+// Proof is an identity proof, e.g. of a Twitter account
+type Proof struct {
 	HumanURL          string `json:"human_url"`
 	Nametag           string `json:"nametag"`
 	PresentationGroup string `json:"presentation_group"`
@@ -727,53 +806,59 @@ type proof struct {
 	State             int    `json:"state"`
 }
 
-type proofsSummary struct {
-	All    []proof `json:"all"`
+// ProofsSummary holds a user's identity proofs
+type ProofsSummary struct {
+	All    []Proof `json:"all"`
 	HasWeb bool    `json:"has_web"`
 }
 
-type key struct {
+// DeviceKey is a key of a UserDevice
+type DeviceKey struct {
 	KeyRole int    `json:"key_role"`
 	Kid     string `json:"kid"`
 	SigID   string `json:"sig_id"`
 }
 
-type uDevice struct {
-	Ctime  int    `json:"ctime"`
-	Keys   []key  `json:"keys"`
-	Mtime  int    `json:"mtime"`
-	Name   string `json:"name"`
-	Status int    `json:"status"`
-	Type   string `json:"type"`
+// UserDevice is a device of a user, as returned by UserLookup
+type UserDevice struct {
+	Ctime  int         `json:"ctime"`
+	Keys   []DeviceKey `json:"keys"`
+	Mtime  int         `json:"mtime"`
+	Name   string      `json:"name"`
+	Status int         `json:"status"`
+	Type   string      `json:"type"`
 }
 
-type them struct {
-	Basics        basics             `json:"basics,omitempty"`
-	ID            string             `json:"id"`
-	Profile       profile            `json:"profile,omitempty"`
-	ProofsSummary proofsSummary      `json:"proofs_summary"`
-	Devices       map[string]uDevice `json:"devices,omitempty"`
+// UserInfo holds information about a user, as returned by UserLookup
+type UserInfo struct {
+	Basics        UserBasics            `json:"basics,omitempty"`
+	ID            string                `json:"id"`
+	Profile       UserProfile           `json:"profile,omitempty"`
+	ProofsSummary ProofsSummary         `json:"proofs_summary"`
+	Devices       map[string]UserDevice `json:"devices,omitempty"`
 }
 
 // UserCardAPI holds information received from the user/card api
 type UserCardAPI struct {
 	AirdropRegistered bool           `json:"airdrop_registered"`
 	Blocked           bool           `json:"blocked"`
-	FollowSummary     followSummary  `json:"follow_summary"`
-	Profile           cardProfile    `json:"profile"`
-	Status            uStatus        `json:"status"`
-	TeamShowcase      []teamShowcase `json:"team_showcase"`
+	FollowSummary     FollowSummary  `json:"follow_summary"`
+	Profile           CardProfile    `json:"profile"`
+	Status            APIStatus      `json:"status"`
+	TeamShowcase      []TeamShowcase `json:"team_showcase"`
 	TheyFollowYou     bool           `json:"they_follow_you"`
-	UserBlocks        userBlocks     `json:"user_blocks"`
+	UserBlocks        UserBlocks     `json:"user_blocks"`
 	YouFollowThem     bool           `json:"you_follow_them"`
 }
 
-type followSummary struct {
+// FollowSummary holds how many users a user follows, and is followed by
+type FollowSummary struct {
 	Followers int `json:"followers"`
 	Following int `json:"following"`
 }
 
-type cardProfile struct {
+// CardProfile is the profile shown on a user's card
+type CardProfile struct {
 	Bio                    string    `json:"bio"`
 	Comment                string    `json:"comment"`
 	CrimeAll               int       `json:"crime_all"`
@@ -801,7 +886,8 @@ type cardProfile struct {
 	Website                string    `json:"website"`
 }
 
-type teamShowcase struct {
+// TeamShowcase is a team shown on a user's card
+type TeamShowcase struct {
 	Description     string   `json:"description"`
 	FqName          string   `json:"fq_name"`
 	NumMembers      int      `json:"num_members"`
@@ -812,7 +898,8 @@ type teamShowcase struct {
 	TeamIsShowcased bool     `json:"team_is_showcased"`
 }
 
-type userBlocks struct {
+// UserBlocks holds whether the current user blocked a user
+type UserBlocks struct {
 	Chat   bool      `json:"chat"`
 	Ctime  time.Time `json:"ctime"`
 	Follow bool      `json:"follow"`
@@ -821,11 +908,36 @@ type userBlocks struct {
 
 // Keybase holds basic information about the local Keybase executable
 type Keybase struct {
-	Path     string
-	Username string
-	LoggedIn bool
-	Version  string
-	Device   string
+	Path       string
+	Username   string
+	LoggedIn   bool
+	Version    string
+	Device     string
+	Home       string        // Keybase home directory, passed to keybase as --home if set
+	SocketFile string        // Keybase service socket, passed to keybase as --socket-file if set
+	Env        []string      // Extra environment variables for keybase commands, in the form "key=value"
+	Timeout    time.Duration // Default timeout for commands whose context has no deadline (0 = none)
+	Logger     Logger        // Receives diagnostic messages, if set
+	Executor   Executor      // Runs keybase commands. Defaults to running the binary at Path if nil
+	Sessions   bool          // Send API requests through one long-lived subprocess per API instead of one subprocess per request. Set by New and NewKeybase
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	ctx      context.Context // Lifetime of listeners started by Run. Cancelled by Close
+	cancel   context.CancelFunc
+	service  *managedService // Service started by StartService, if any
+	recorder *Recorder       // Set by WithRecorder
+
+	hooks         []Hook    // Set by WithHooks
+	redaction     Redaction // Set by WithRedaction
+	showPaperKeys bool      // Set by WithoutPaperKeyRedaction
+	metrics       Metrics   // Set by WithMetrics
+
+	limiter       rateLimiter   // Rate limits reported by the chat API
+	rateLimitWait time.Duration // Set by WithRateLimitWait
+	retryPolicy   RetryPolicy   // Set by WithRetryPolicy
+
+	startService bool // Set by WithService
 }
 
 // Chat holds basic information about a specific conversation
@@ -834,93 +946,98 @@ type Chat struct {
 	Channel Channel
 }
 
-type chat interface {
-	Delete(messageID int) (ChatAPI, error)
-	Edit(messageID int, message ...string) (ChatAPI, error)
-	React(messageID int, reaction string) (ChatAPI, error)
-	Send(message ...string) (ChatAPI, error)
-	Reply(replyTo int, message ...string) (ChatAPI, error)
-	Upload(title string, filepath string) (ChatAPI, error)
-	Download(messageID int, filepath string) (ChatAPI, error)
-	LoadFlip(messageID int, conversationID string, flipConversationID string, gameID string) (ChatAPI, error)
-	Pin(messageID int) (ChatAPI, error)
-	Unpin() (ChatAPI, error)
-	Mark(messageID int) (ChatAPI, error)
-}
-
-type chatAPI interface {
-	Next(count ...int) (*ChatAPI, error)
-	Previous(count ...int) (*ChatAPI, error)
-}
-
 // Team holds basic information about a team
 type Team struct {
 	keybase *Keybase
 	Name    string
 }
 
-type team interface {
-	AddAdmins(users ...string) (TeamAPI, error)
-	AddOwners(users ...string) (TeamAPI, error)
-	AddReaders(users ...string) (TeamAPI, error)
-	AddUser(user, role string) (TeamAPI, error)
-	AddWriters(users ...string) (TeamAPI, error)
-	CreateSubteam(name string) (TeamAPI, error)
-	MemberList() (TeamAPI, error)
-}
-
 // Wallet holds basic information about a wallet
 type Wallet struct {
 	keybase *Keybase
 }
 
-type wallet interface {
-	CancelRequest(requestID string) error
-	RequestPayment(user string, amount float64, memo ...string)
-	Send(recipient string, amount string, currency string, message ...string) (WalletAPI, error)
-	SendXLM(recipient string, amount string, message ...string) (WalletAPI, error)
-	StellarAddress(user string) (string, error)
-	TxDetail(txid string) (WalletAPI, error)
-}
-
 // KV holds basic information about a KVStore
 type KV struct {
 	keybase *Keybase
 	Team    string
 }
 
-type kvInterface interface {
-	Namespaces() (KVAPI, error)
-	Keys(namespace string) (KVAPI, error)
-	Get(namespace string, key string) (KVAPI, error)
-	Put(namespace string, key string, value string) (KVAPI, error)
-	Delete(namespace string, key string) (KVAPI, error)
-}
-
-type keybase interface {
-	AdvertiseCommand(advertisement BotAdvertisement) (ChatAPI, error)
-	AdvertiseCommands(advertisements []BotAdvertisement) (ChatAPI, error)
-	ChatList(opts ...Channel) (ChatAPI, error)
-	ClearCommands() (ChatAPI, error)
-	CreateTeam(name string) (TeamAPI, error)
-	NewChat(channel Channel) Chat
-	NewTeam(name string) Team
-	NewKV(team string) KV
-	NewWallet() Wallet
-	Run(handler func(ChatAPI), options ...RunOptions)
-	status() status
-	version() string
-	UserLookup(users ...string) (UserAPI, error)
-	ListUserMemberships(user string) (TeamAPI, error)
-	UserCard(user string) (UserCardAPI, error)
-}
-
-type status struct {
-	Username string `json:"Username"`
-	LoggedIn bool   `json:"LoggedIn"`
-	Device   device `json:"Device"`
-}
-
-type device struct {
-	Name string `json:"name"`
+// Status holds information returned by the `keybase status` command
+type Status struct {
+	Username               string        `json:"Username"`
+	UserID                 string        `json:"UserID"`
+	Device                 Device        `json:"Device"`
+	LoggedIn               bool          `json:"LoggedIn"`
+	SessionStatus          string        `json:"SessionStatus"`
+	PassphraseStreamCached bool          `json:"PassphraseStreamCached"`
+	TsecCached             bool          `json:"TsecCached"`
+	DeviceSigKeyCached     bool          `json:"DeviceSigKeyCached"`
+	DeviceEncKeyCached     bool          `json:"DeviceEncKeyCached"`
+	PaperSigKeyCached      bool          `json:"PaperSigKeyCached"`
+	PaperEncKeyCached      bool          `json:"PaperEncKeyCached"`
+	StoredSecret           bool          `json:"StoredSecret"`
+	SecretPromptSkip       bool          `json:"SecretPromptSkip"`
+	RememberPassphrase     bool          `json:"RememberPassphrase"`
+	Client                 ClientStatus  `json:"Client"`
+	Service                ServiceStatus `json:"Service"`
+	KBFS                   KBFSStatus    `json:"KBFS"`
+	Desktop                DesktopStatus `json:"Desktop"`
+	DefaultUsername        string        `json:"DefaultUsername"`
+	ProvisionedUsernames   []string      `json:"ProvisionedUsernames"`
+	PlatformInfo           PlatformInfo  `json:"PlatformInfo"`
+	OSVersion              string        `json:"OSVersion"`
+	DeviceEKNames          []string      `json:"DeviceEKNames"`
+}
+
+// Device is the current device, as reported by `keybase status`
+type Device struct {
+	Type               string `json:"type"`
+	Name               string `json:"name"`
+	DeviceID           string `json:"deviceID"`
+	DeviceNumberOfType int    `json:"deviceNumberOfType"`
+	CTime              int64  `json:"cTime"`
+	MTime              int64  `json:"mTime"`
+	LastUsedTime       int64  `json:"lastUsedTime"`
+	EncryptKey         string `json:"encryptKey"`
+	VerifyKey          string `json:"verifyKey"`
+	Status             int    `json:"status"`
+}
+
+// ClientStatus describes the keybase client
+type ClientStatus struct {
+	Version string `json:"Version"`
+}
+
+// ServiceStatus describes the keybase service
+type ServiceStatus struct {
+	Version string `json:"Version"`
+	Running bool   `json:"Running"`
+	Pid     string `json:"Pid"`
+	Log     string `json:"Log"`
+	EkLog   string `json:"EkLog"`
+}
+
+// KBFSStatus describes the keybase filesystem
+type KBFSStatus struct {
+	Version string `json:"Version"`
+	Running bool   `json:"Running"`
+	Pid     string `json:"Pid"`
+	Log     string `json:"Log"`
+	Mount   string `json:"Mount"`
+}
+
+// DesktopStatus describes the keybase desktop app
+type DesktopStatus struct {
+	Version string `json:"Version"`
+	Running bool   `json:"Running"`
+	Log     string `json:"Log"`
+}
+
+// PlatformInfo describes the platform keybase is running on
+type PlatformInfo struct {
+	OS        string `json:"os"`
+	OSVersion string `json:"osVersion"`
+	Arch      string `json:"arch"`
+	GoVersion string `json:"goVersion"`
 }
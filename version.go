@@ -0,0 +1,139 @@
+package keybase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a parsed keybase client version, such as 5.5.2-20200710162215+e3cd3d5d70
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string // Build timestamp for nightly and release builds
+	Build      string // Commit hash
+}
+
+// ParseVersion parses a version string, as printed by `keybase version -S`
+func ParseVersion(s string) (SemVer, error) {
+	var v SemVer
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s, v.Build = s[:i], s[i+1:]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.Prerelease = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("keybase: invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return SemVer{}, fmt.Errorf("keybase: invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// String returns the version in the same format it was parsed from
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is older than, the same
+// as, or newer than o. Only the major, minor and patch numbers are compared.
+func (v SemVer) Compare(o SemVer) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{o.Major, o.Minor, o.Patch}
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast reports whether v is the same as or newer than o
+func (v SemVer) AtLeast(o SemVer) bool {
+	return v.Compare(o) >= 0
+}
+
+// capabilities holds the minimum client version for JSON API methods that
+// weren't available in every version of the API. Methods are keyed by the
+// API they belong to, then by name. Methods that aren't listed are assumed
+// to be supported everywhere. Each version is the keybase/client release
+// that first shipped the method, as listed in its release notes.
+var capabilities = map[string]map[string]SemVer{
+	"chat": {
+		// keybase/client v4.4.0 added inbox search to the chat API
+		"searchinbox": {Major: 4, Minor: 4},
+
+		// keybase/client v4.7.0 added bot command advertisements
+		"advertisecommands": {Major: 4, Minor: 7},
+		"clearcommands":     {Major: 4, Minor: 7},
+
+		// keybase/client v5.0.0 added pinned messages
+		"pin":   {Major: 5, Minor: 0},
+		"unpin": {Major: 5, Minor: 0},
+
+		// keybase/client v5.2.0 added restricted bots
+		"addbotmember":    {Major: 5, Minor: 2},
+		"removebotmember": {Major: 5, Minor: 2},
+
+		// keybase/client v5.6.0 added custom emoji
+		"addemoji": {Major: 5, Minor: 6},
+	},
+	"kvstore": {
+		// keybase/client v5.1.0 added `keybase kvstore api`
+		"list": {Major: 5, Minor: 1},
+		"get":  {Major: 5, Minor: 1},
+		"put":  {Major: 5, Minor: 1},
+		"del":  {Major: 5, Minor: 1},
+	},
+}
+
+// SemVer returns k's parsed client version
+func (k *Keybase) SemVer() (SemVer, error) {
+	return ParseVersion(k.Version)
+}
+
+// Supports reports whether k's client supports the given method of the given
+// JSON API, e.g. Supports("chat", "pin"). If the client version is
+// unknown, every method is assumed to be supported.
+func (k *Keybase) Supports(api, method string) bool {
+	return k.checkSupported(api, method) == nil
+}
+
+// checkSupported returns an error wrapping ErrUnsupported if k's client is too
+// old for the given method of the given JSON API
+func (k *Keybase) checkSupported(api, method string) error {
+	min, ok := capabilities[api][method]
+	if !ok {
+		return nil
+	}
+	v, err := k.SemVer()
+	if err != nil {
+		return nil
+	}
+	if !v.AtLeast(min) {
+		return fmt.Errorf("%w: %s api method %q requires keybase %s or newer, have %s", ErrUnsupported, api, method, min, v)
+	}
+	return nil
+}
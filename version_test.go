@@ -0,0 +1,74 @@
+package keybase
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SemVer
+	}{
+		{"5.5.2-20200710162215+e3cd3d5d70\n", SemVer{5, 5, 2, "20200710162215", "e3cd3d5d70"}},
+		{"4.7.0", SemVer{Major: 4, Minor: 7}},
+		{"v5.0.1+abc", SemVer{Major: 5, Patch: 1, Build: "abc"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if err != nil {
+			t.Errorf("ParseVersion(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "5.5", "5.x.0", "5.5.-1"} {
+		if _, err := ParseVersion(in); err == nil {
+			t.Errorf("ParseVersion(%q) succeeded", in)
+		}
+	}
+}
+
+func TestSemVerCompare(t *testing.T) {
+	a, b := SemVer{Major: 5, Minor: 2}, SemVer{Major: 5, Minor: 10}
+	if a.Compare(b) != -1 || b.Compare(a) != 1 || a.Compare(a) != 0 {
+		t.Errorf("Compare is inconsistent for %v and %v", a, b)
+	}
+	if !b.AtLeast(a) || a.AtLeast(b) {
+		t.Errorf("AtLeast is inconsistent for %v and %v", a, b)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	e := &fakeExecutor{outputs: map[string]string{"chat api": `{"result":{}}`}}
+	k := &Keybase{Executor: e, Version: "4.7.1"}
+
+	_, err := k.NewChat(Channel{Name: "team"}).Pin(42)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Pin() error = %v, want %v", err, ErrUnsupported)
+	}
+	if calls, _ := e.history(); len(calls) != 0 {
+		t.Errorf("unsupported request was sent: %q", calls)
+	}
+	if _, err := k.NewChat(Channel{Name: "team"}).Send("hi"); err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+
+	k.Version = "4.3.2"
+	if _, err := k.SearchInbox("hello"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SearchInbox() error = %v, want %v", err, ErrUnsupported)
+	}
+
+	k.Version = "5.5.2-20200710162215+e3cd3d5d70"
+	if _, err := k.NewChat(Channel{Name: "team"}).AddEmoji("party", "/tmp/party.gif"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("AddEmoji() error = %v, want %v", err, ErrUnsupported)
+	}
+
+	k.Version = ""
+	if !k.Supports("chat", "pin") {
+		t.Error("methods should be assumed supported when the version is unknown")
+	}
+}
@@ -1,50 +1,75 @@
 package keybase
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"strings"
 )
 
 // walletAPIOut sends JSON requests to the wallet API and returns its response.
-func walletAPIOut(k *Keybase, w WalletAPI) (WalletAPI, error) {
+func walletAPIOut(ctx context.Context, k *Keybase, w WalletAPI) (WalletAPI, error) {
+	if err := k.checkSupported("wallet", w.Method); err != nil {
+		return WalletAPI{}, err
+	}
+	var r WalletAPI
+	err := k.retry(ctx, "wallet", w.Method, func(ctx context.Context) (err error) {
+		r, err = walletAPICall(ctx, k, w)
+		return err
+	})
+	return r, err
+}
+
+// walletAPICall sends a single JSON request to the wallet API
+func walletAPICall(ctx context.Context, k *Keybase, w WalletAPI) (WalletAPI, error) {
 	jsonBytes, _ := json.Marshal(w)
 
-	cmdOut, err := k.Exec("wallet", "api", "-m", string(jsonBytes))
+	cmdOut, err := k.api(ctx, "wallet", w.Method, jsonBytes)
 	if err != nil {
-		return WalletAPI{}, err
+		return WalletAPI{}, withMethod(err, w.Method)
 	}
 
 	var r WalletAPI
-	json.Unmarshal(cmdOut, &r)
+	if err := json.Unmarshal(cmdOut, &r); err != nil {
+		return WalletAPI{}, decodeError(w.Method, err)
+	}
 	if r.Error != nil {
-		return WalletAPI{}, errors.New(r.Error.Message)
+		return WalletAPI{}, newResponseError(w.Method, *r.Error)
 	}
 	return r, nil
 }
 
 // TxDetail returns details of a stellar transaction
 func (w Wallet) TxDetail(txid string) (WalletAPI, error) {
+	return w.TxDetailContext(context.Background(), txid)
+}
+
+// TxDetailContext is like TxDetail, but aborts the request when ctx is done
+func (w Wallet) TxDetailContext(ctx context.Context, txid string) (WalletAPI, error) {
 	m := WalletAPI{
-		Params: &wParams{},
+		Params: &WalletParams{},
 	}
 	m.Method = "details"
 	m.Params.Options.Txid = txid
 
-	r, err := walletAPIOut(w.keybase, m)
+	r, err := walletAPIOut(ctx, w.keybase, m)
 	return r, err
 }
 
 // StellarAddress returns the primary stellar address of a given user
 func (w Wallet) StellarAddress(user string) (string, error) {
+	return w.StellarAddressContext(context.Background(), user)
+}
+
+// StellarAddressContext is like StellarAddress, but aborts the request when ctx is done
+func (w Wallet) StellarAddressContext(ctx context.Context, user string) (string, error) {
 	m := WalletAPI{
-		Params: &wParams{},
+		Params: &WalletParams{},
 	}
 	m.Method = "lookup"
 	m.Params.Options.Name = user
 
-	r, err := walletAPIOut(w.keybase, m)
+	r, err := walletAPIOut(ctx, w.keybase, m)
 	if err != nil {
 		return "", err
 	}
@@ -53,13 +78,18 @@ func (w Wallet) StellarAddress(user string) (string, error) {
 
 // StellarUser returns the keybase username of a given wallet address
 func (w Wallet) StellarUser(wallet string) (string, error) {
+	return w.StellarUserContext(context.Background(), wallet)
+}
+
+// StellarUserContext is like StellarUser, but aborts the request when ctx is done
+func (w Wallet) StellarUserContext(ctx context.Context, wallet string) (string, error) {
 	m := WalletAPI{
-		Params: &wParams{},
+		Params: &WalletParams{},
 	}
 	m.Method = "lookup"
 	m.Params.Options.Name = wallet
 
-	r, err := walletAPIOut(w.keybase, m)
+	r, err := walletAPIOut(ctx, w.keybase, m)
 	if err != nil {
 		return "", err
 	}
@@ -68,26 +98,41 @@ func (w Wallet) StellarUser(wallet string) (string, error) {
 
 // RequestPayment sends a request for payment to a user
 func (w Wallet) RequestPayment(user string, amount float64, memo ...string) error {
+	return w.RequestPaymentContext(context.Background(), user, amount, memo...)
+}
+
+// RequestPaymentContext is like RequestPayment, but aborts the request when ctx is done
+func (w Wallet) RequestPaymentContext(ctx context.Context, user string, amount float64, memo ...string) error {
 	k := w.keybase
 	if len(memo) > 0 {
-		_, err := k.Exec("wallet", "request", user, fmt.Sprintf("%f", amount), "-m", memo[0])
+		_, err := k.ExecContext(ctx, "wallet", "request", user, fmt.Sprintf("%f", amount), "-m", memo[0])
 		return err
 	}
-	_, err := k.Exec("wallet", "request", user, fmt.Sprintf("%f", amount))
+	_, err := k.ExecContext(ctx, "wallet", "request", user, fmt.Sprintf("%f", amount))
 	return err
 }
 
 // CancelRequest cancels a request for payment previously sent to a user
 func (w Wallet) CancelRequest(requestID string) error {
+	return w.CancelRequestContext(context.Background(), requestID)
+}
+
+// CancelRequestContext is like CancelRequest, but aborts the request when ctx is done
+func (w Wallet) CancelRequestContext(ctx context.Context, requestID string) error {
 	k := w.keybase
-	_, err := k.Exec("wallet", "cancel-request", requestID)
+	_, err := k.ExecContext(ctx, "wallet", "cancel-request", requestID)
 	return err
 }
 
 // Send sends the specified amount of the specified currency to a user
 func (w Wallet) Send(recipient string, amount string, currency string, message ...string) (WalletAPI, error) {
+	return w.SendContext(context.Background(), recipient, amount, currency, message...)
+}
+
+// SendContext is like Send, but aborts the request when ctx is done
+func (w Wallet) SendContext(ctx context.Context, recipient string, amount string, currency string, message ...string) (WalletAPI, error) {
 	m := WalletAPI{
-		Params: &wParams{},
+		Params: &WalletParams{},
 	}
 	m.Method = "send"
 	m.Params.Options.Recipient = recipient
@@ -97,7 +142,7 @@ func (w Wallet) Send(recipient string, amount string, currency string, message .
 		m.Params.Options.Message = strings.Join(message, " ")
 	}
 
-	r, err := walletAPIOut(w.keybase, m)
+	r, err := walletAPIOut(ctx, w.keybase, m)
 	if err != nil {
 		return WalletAPI{}, err
 	}
@@ -106,6 +151,11 @@ func (w Wallet) Send(recipient string, amount string, currency string, message .
 
 // SendXLM sends the specified amount of XLM to a user
 func (w Wallet) SendXLM(recipient string, amount string, message ...string) (WalletAPI, error) {
-	result, err := w.Send(recipient, amount, "XLM", message...)
+	return w.SendXLMContext(context.Background(), recipient, amount, message...)
+}
+
+// SendXLMContext is like SendXLM, but aborts the request when ctx is done
+func (w Wallet) SendXLMContext(ctx context.Context, recipient string, amount string, message ...string) (WalletAPI, error) {
+	result, err := w.SendContext(ctx, recipient, amount, "XLM", message...)
 	return result, err
 }